@@ -0,0 +1,100 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AccessLogFields lists the fields JSONLoggingRouter can log
+var AccessLogFields = []string{"method", "path", "pattern", "status", "duration_ms", "bytes", "client_ip", "trace_id", "user_id"}
+
+// JSONLogOptions configures JSONLoggingRouter
+type JSONLogOptions struct {
+	// Writer receives one JSON object per line for every request. Defaults to os.Stderr.
+	Writer io.Writer
+	// Fields selects the logged fields out of AccessLogFields. Defaults to all fields.
+	// Empty values, e.g. the user_id of an anonymous request, are omitted.
+	Fields []string
+}
+
+// requestInfo is filled in by the Router serving a request, so that middlewares wrapping the
+// Router can log the matched route and the RouteContext
+type requestInfo struct {
+	pattern      string
+	routeContext *RouteContext
+}
+
+var contextKeyRequestInfo = contextKey("requestInfo")
+
+// accessLogWriter counts the bytes of the response body
+type accessLogWriter struct {
+	statusWriter
+	bytes int
+}
+
+func (aw *accessLogWriter) Write(b []byte) (int, error) {
+	n, err := aw.statusWriter.Write(b)
+	aw.bytes += n
+	return n, err
+}
+
+// JSONLoggingRouter is a middleware that writes an access log line as JSON for every request.
+// The pattern is the template of the matched route and user_id is the user ID set on the
+// RouteContext, e.g. by the AuthorizationMiddleware.
+func JSONLoggingRouter(next http.Handler, opts JSONLogOptions) http.Handler {
+	if opts.Writer == nil {
+		opts.Writer = os.Stderr
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = AccessLogFields
+	}
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		aw := &accessLogWriter{statusWriter: statusWriter{ResponseWriter: w}}
+		next.ServeHTTP(aw, r.WithContext(context.WithValue(r.Context(), contextKeyRequestInfo, info)))
+
+		status := aw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		values := map[string]interface{}{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      status,
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":       aw.bytes,
+			"client_ip":   ClientIP(r),
+		}
+		if info.pattern != "" {
+			values["pattern"] = info.pattern
+		}
+		if traceID, ok := r.Context().Value(contextKeyTraceID).(string); ok {
+			values["trace_id"] = traceID
+		}
+		if info.routeContext != nil && info.routeContext.userId != "" {
+			values["user_id"] = info.routeContext.userId
+		}
+
+		entry := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if value, ok := values[field]; ok {
+				entry[field] = value
+			}
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		opts.Writer.Write(append(line, '\n'))
+	})
+}
@@ -0,0 +1,70 @@
+package restapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONLoggingRouter(t *testing.T) {
+	router := &Router{
+		AuthorizationGuard: func(r *http.Request, ctx *RouteContext) error {
+			ctx.SetUserId("user-7")
+			return nil
+		},
+		PermissionGuard: func(r *http.Request, ctx *RouteContext) error { return nil },
+	}
+	router.HandleProtectedFunc("GET", "/users/:id", nil, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	})
+
+	t.Run("Logs all fields with their types", func(t *testing.T) {
+		var out bytes.Buffer
+		handler := TracingRouter(JSONLoggingRouter(router, JSONLogOptions{Writer: &out}))
+		req := httptest.NewRequest("GET", "/users/42", nil)
+		req.RemoteAddr = "203.0.113.9:51234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected a JSON log line, got '%s'", out.String())
+		}
+		expected := map[string]interface{}{
+			"method":    "GET",
+			"path":      "/users/42",
+			"pattern":   "/users/:id",
+			"status":    float64(http.StatusAccepted),
+			"bytes":     float64(5),
+			"client_ip": "203.0.113.9",
+			"user_id":   "user-7",
+		}
+		for field, value := range expected {
+			if entry[field] != value {
+				t.Errorf("Expected %s to be %v, got %v", field, value, entry[field])
+			}
+		}
+		if _, ok := entry["duration_ms"].(float64); !ok {
+			t.Errorf("Expected duration_ms to be a number, got %v", entry["duration_ms"])
+		}
+		if traceID, ok := entry["trace_id"].(string); !ok || traceID == "" {
+			t.Errorf("Expected trace_id to be a string, got %v", entry["trace_id"])
+		}
+	})
+
+	t.Run("Logs only the configured fields", func(t *testing.T) {
+		var out bytes.Buffer
+		handler := JSONLoggingRouter(router, JSONLogOptions{Writer: &out, Fields: []string{"method", "status"}})
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if len(entry) != 2 || entry["method"] != "GET" || entry["status"] != float64(http.StatusNotFound) {
+			t.Errorf("Expected only method and status, got %v", entry)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package restapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BasicAuthMiddleware returns a function usable as Router.AuthorizationMiddleware that authenticates
+// requests with HTTP Basic authentication. verify checks the credentials and returns the permissions
+// of the user. On success the user name and permissions are set on the RouteContext, otherwise
+// the request is rejected with 401 and a WWW-Authenticate challenge.
+func BasicAuthMiddleware(verify func(user, pass string) ([]Permission, bool)) func(context *RouteContext, handler http.Handler) http.Handler {
+	return func(context *RouteContext, handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if ok {
+				if permissions, valid := verify(user, pass); valid {
+					context.SetUserId(user)
+					context.SetUserPermissions(permissions)
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
+			WriteError(w, NewHTTPError(http.StatusUnauthorized, ""))
+		})
+	}
+}
+
+// BearerTokenMiddleware returns a function usable as Router.AuthorizationMiddleware that
+// authenticates requests with a bearer token from the Authorization header. validate checks the
+// token, e.g. by verifying a JWT, and returns the user ID and permissions, which are set on the
+// RouteContext. Missing or invalid tokens are rejected with 401, unless validate returns an
+// HTTPError or registered error, whose status is used instead.
+func BearerTokenMiddleware(validate func(token string) (userId string, permissions []Permission, err error)) func(context *RouteContext, handler http.Handler) http.Handler {
+	return func(context *RouteContext, handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			token = strings.TrimSpace(token)
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				WriteError(w, NewHTTPError(http.StatusUnauthorized, ""))
+				return
+			}
+			userId, permissions, err := validate(token)
+			if err != nil {
+				if status, mapped := lookupErrorStatus(err); mapped {
+					writeErrorResponse(w, err, status, true)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				WriteError(w, NewHTTPError(http.StatusUnauthorized, ""))
+				return
+			}
+			context.SetUserId(userId)
+			context.SetUserPermissions(permissions)
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DefaultPermissionMiddleware returns a function usable as Router.PermissionMiddleware that
+// responds with 403 unless the user has all permissions required by the route. getUserPerms
+// returns the permissions of the authenticated user. When nil, the permissions set on the
+// RouteContext by the AuthorizationMiddleware, e.g. BasicAuthMiddleware, are used.
+func DefaultPermissionMiddleware(getUserPerms func(ctx *RouteContext) []Permission) func(context *RouteContext, handler http.Handler) http.Handler {
+	if getUserPerms == nil {
+		getUserPerms = (*RouteContext).GetUserPermissions
+	}
+	return func(context *RouteContext, handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !context.HasRequiredPermissions(getUserPerms(context)) {
+				WriteError(w, NewHTTPError(http.StatusForbidden, ""))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
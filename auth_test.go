@@ -0,0 +1,179 @@
+package restapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	const permissionRead Permission = 1
+	router := &Router{
+		AuthorizationMiddleware: BasicAuthMiddleware(func(user, pass string) ([]Permission, bool) {
+			if user == "admin" && pass == "secret" {
+				return []Permission{permissionRead}, true
+			}
+			return nil, false
+		}),
+		PermissionMiddleware: func(context *RouteContext, handler http.Handler) http.Handler {
+			return handler
+		},
+	}
+	var userId string
+	var permissions []Permission
+	router.HandleProtectedFunc("GET", "/reports", []Permission{permissionRead}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		userId, _ = ctx.GetUserId()
+		permissions = ctx.GetUserPermissions()
+	})
+
+	t.Run("Valid credentials reach the handler", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/reports", nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if userId != "admin" {
+			t.Errorf("Expected user 'admin', got '%s'", userId)
+		}
+		if len(permissions) != 1 || permissions[0] != permissionRead {
+			t.Errorf("Expected permissions [%d], got %v", permissionRead, permissions)
+		}
+	})
+
+	tests := []struct {
+		name string
+		auth func(req *http.Request)
+	}{
+		{"Missing credentials", func(req *http.Request) {}},
+		{"Wrong password", func(req *http.Request) { req.SetBasicAuth("admin", "wrong") }},
+		{"Other scheme", func(req *http.Request) { req.Header.Set("Authorization", "Bearer token") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/reports", nil)
+			tt.auth(req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d", w.Code)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); challenge == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestBearerTokenMiddleware(t *testing.T) {
+	const permissionWrite Permission = 2
+	errTokenRevoked := errors.New("token revoked")
+	defer func() { errorStatuses = []errorStatus{} }()
+	RegisterErrorStatus(errTokenRevoked, http.StatusForbidden)
+
+	router := &Router{
+		AuthorizationMiddleware: BearerTokenMiddleware(func(token string) (string, []Permission, error) {
+			switch token {
+			case "valid-token":
+				return "user-1", []Permission{permissionWrite}, nil
+			case "revoked-token":
+				return "", nil, errTokenRevoked
+			}
+			return "", nil, errors.New("signature mismatch")
+		}),
+		PermissionMiddleware: func(context *RouteContext, handler http.Handler) http.Handler {
+			return handler
+		},
+	}
+	var userId string
+	var permissions []Permission
+	router.HandleProtectedFunc("POST", "/posts", []Permission{permissionWrite}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		userId, _ = ctx.GetUserId()
+		permissions = ctx.GetUserPermissions()
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"Valid token reaches the handler", "Bearer valid-token", http.StatusOK},
+		{"Missing header", "", http.StatusUnauthorized},
+		{"Other scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"Invalid token", "Bearer forged-token", http.StatusUnauthorized},
+		{"Registered error status is used", "Bearer revoked-token", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/posts", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+			if strings.Contains(w.Body.String(), "signature mismatch") {
+				t.Error("Validation error details should not be leaked")
+			}
+		})
+	}
+
+	if userId != "user-1" || len(permissions) != 1 || permissions[0] != permissionWrite {
+		t.Errorf("Expected user-1 with permissions [%d], got '%s' %v", permissionWrite, userId, permissions)
+	}
+}
+
+func TestDefaultPermissionMiddleware(t *testing.T) {
+	const (
+		permissionRead Permission = iota + 1
+		permissionAdmin
+	)
+	authorization := BasicAuthMiddleware(func(user, pass string) ([]Permission, bool) {
+		if user == "admin" {
+			return []Permission{permissionRead, permissionAdmin}, true
+		}
+		return []Permission{permissionRead}, true
+	})
+
+	tests := []struct {
+		name         string
+		getUserPerms func(ctx *RouteContext) []Permission
+		user         string
+		expected     int
+	}{
+		{"User with the required permissions", nil, "admin", http.StatusOK},
+		{"User lacking a required permission", nil, "reader", http.StatusForbidden},
+		{"Permissions from a custom lookup", func(ctx *RouteContext) []Permission {
+			return []Permission{permissionRead, permissionAdmin}
+		}, "reader", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := &Router{
+				AuthorizationMiddleware: authorization,
+				PermissionMiddleware:    DefaultPermissionMiddleware(tt.getUserPerms),
+			}
+			router.HandleProtectedFunc("DELETE", "/users/:id", []Permission{permissionAdmin}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+
+			req := httptest.NewRequest("DELETE", "/users/1", nil)
+			req.SetBasicAuth(tt.user, "secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
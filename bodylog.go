@@ -0,0 +1,146 @@
+package restapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxLoggedBodySize = 4096
+
+// BodyLogEntry is passed to BodyLogOptions.LogFunc for every request
+type BodyLogEntry struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Status       int    `json:"status"`
+	RequestBody  string `json:"request_body,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+	TraceID      string `json:"trace_id,omitempty"`
+}
+
+// BodyLogOptions configures BodyLoggingRouter
+type BodyLogOptions struct {
+	// LogFunc is called with the captured bodies after the response is written
+	LogFunc func(entry BodyLogEntry)
+	// RedactedFields lists JSON field names, matched case-insensitively at any depth,
+	// whose values are replaced with "[REDACTED]"
+	RedactedFields []string
+	// MaxBodySize caps the logged size of each body in bytes. Defaults to 4096.
+	MaxBodySize int
+}
+
+// bodyCaptureWriter passes the response through while capturing up to limit bytes of the body
+type bodyCaptureWriter struct {
+	statusWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (bw *bodyCaptureWriter) Write(b []byte) (int, error) {
+	remaining := bw.limit - bw.body.Len()
+	bw.truncated = bw.truncated || len(b) > remaining
+	if remaining > 0 {
+		bw.body.Write(b[:min(len(b), remaining)])
+	}
+	return bw.statusWriter.Write(b)
+}
+
+// readCloser reads from Reader and closes Closer, e.g. a request body that was partly read ahead
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// BodyLoggingRouter is a middleware that logs request and response bodies for debugging.
+// JSON bodies are logged with the RedactedFields replaced. Only up to MaxBodySize bytes of the
+// request body are read ahead and restored so that next can read the whole body. The response is
+// written through while it is captured.
+func BodyLoggingRouter(next http.Handler, opts BodyLogOptions) http.Handler {
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultMaxLoggedBodySize
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody []byte
+		if r.Body != nil {
+			// only what can be logged is read, the rest is left for next to read or limit
+			var err error
+			requestBody, err = io.ReadAll(io.LimitReader(r.Body, int64(opts.MaxBodySize)+1))
+			if err != nil {
+				WriteError(w, NewHTTPError(http.StatusBadRequest, ""))
+				return
+			}
+			r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(requestBody), r.Body), Closer: r.Body}
+		}
+
+		bw := &bodyCaptureWriter{statusWriter: statusWriter{ResponseWriter: w}, limit: opts.MaxBodySize}
+		next.ServeHTTP(bw, r)
+
+		status := bw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		traceID, _ := r.Context().Value(contextKeyTraceID).(string)
+		opts.LogFunc(BodyLogEntry{
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Status:       status,
+			RequestBody:  opts.formatBody(requestBody, len(requestBody) > opts.MaxBodySize),
+			ResponseBody: opts.formatBody(bw.body.Bytes(), bw.truncated),
+			TraceID:      traceID,
+		})
+	})
+}
+
+// formatBody redacts a JSON body and caps it to MaxBodySize. An incomplete body can't be
+// parsed for redaction, so it is only logged as is when there is nothing to redact.
+func (opts BodyLogOptions) formatBody(body []byte, incomplete bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var value interface{}
+	if !incomplete && json.Unmarshal(body, &value) == nil {
+		if redacted, err := json.Marshal(opts.redact(value)); err == nil {
+			body = redacted
+		}
+	} else if incomplete && len(opts.RedactedFields) > 0 {
+		return fmt.Sprintf("[body larger than %d bytes not logged]", opts.MaxBodySize)
+	}
+	if len(body) > opts.MaxBodySize {
+		return string(body[:opts.MaxBodySize]) + "...[truncated]"
+	}
+	if incomplete {
+		return string(body) + "...[truncated]"
+	}
+	return string(body)
+}
+
+func (opts BodyLogOptions) redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range value {
+			if opts.isRedactedField(key) {
+				value[key] = "[REDACTED]"
+			} else {
+				value[key] = opts.redact(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			value[i] = opts.redact(element)
+		}
+	}
+	return value
+}
+
+func (opts BodyLogOptions) isRedactedField(name string) bool {
+	for _, field := range opts.RedactedFields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package restapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLoggingRouter(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+
+	t.Run("Logs redacted bodies and restores the request body", func(t *testing.T) {
+		var entry BodyLogEntry
+		handler := BodyLoggingRouter(echo, BodyLogOptions{
+			LogFunc:        func(e BodyLogEntry) { entry = e },
+			RedactedFields: []string{"password"},
+		})
+		body := `{"user":{"name":"john","Password":"secret"}}`
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(body)))
+
+		if w.Body.String() != body {
+			t.Errorf("Expected the handler to read the original body, got '%s'", w.Body.String())
+		}
+		expected := `{"user":{"Password":"[REDACTED]","name":"john"}}`
+		if entry.RequestBody != expected {
+			t.Errorf("Expected request body '%s', got '%s'", expected, entry.RequestBody)
+		}
+		if entry.ResponseBody != expected {
+			t.Errorf("Expected response body '%s', got '%s'", expected, entry.ResponseBody)
+		}
+		if entry.Status != http.StatusCreated || entry.Method != "POST" || entry.Path != "/users" {
+			t.Errorf("Unexpected entry: %+v", entry)
+		}
+	})
+
+	t.Run("Caps logged bodies", func(t *testing.T) {
+		var entry BodyLogEntry
+		handler := BodyLoggingRouter(echo, BodyLogOptions{
+			LogFunc:     func(e BodyLogEntry) { entry = e },
+			MaxBodySize: 5,
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/notes", strings.NewReader("hello world")))
+
+		if w.Body.String() != "hello world" {
+			t.Errorf("Expected the full response to be written, got '%s'", w.Body.String())
+		}
+		if entry.RequestBody != "hello...[truncated]" {
+			t.Errorf("Expected truncated request body, got '%s'", entry.RequestBody)
+		}
+		if entry.ResponseBody != "hello...[truncated]" {
+			t.Errorf("Expected truncated response body, got '%s'", entry.ResponseBody)
+		}
+	})
+
+	t.Run("Redacts bodies up to the limit", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body string
+		}{
+			{"Between half and the limit", `{"name":"x","password":"sesame1234"}`},
+			{"Exactly the limit", `{"name":"abcde","password":"sesame1234"}`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var entry BodyLogEntry
+				handler := BodyLoggingRouter(echo, BodyLogOptions{
+					LogFunc:        func(e BodyLogEntry) { entry = e },
+					RedactedFields: []string{"password"},
+					MaxBodySize:    40,
+				})
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(tt.body)))
+
+				expected := strings.Replace(tt.body, `"sesame1234"`, `"[REDACTED]"`, 1)
+				if entry.RequestBody != expected {
+					t.Errorf("Expected request body '%s', got '%s'", expected, entry.RequestBody)
+				}
+				if entry.ResponseBody != expected {
+					t.Errorf("Expected response body '%s', got '%s'", expected, entry.ResponseBody)
+				}
+			})
+		}
+	})
+
+	t.Run("Reads only the logged part of the request body ahead", func(t *testing.T) {
+		body := &countingReader{Reader: strings.NewReader(strings.Repeat("x", 1<<20))}
+		var readAhead int
+		var received int
+		handler := BodyLoggingRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			readAhead = body.read
+			data, _ := io.ReadAll(r.Body)
+			received = len(data)
+		}), BodyLogOptions{LogFunc: func(e BodyLogEntry) {}, MaxBodySize: 16})
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/upload", body))
+
+		if readAhead > 17 {
+			t.Errorf("Expected the body not to be read before the handler, %d bytes were read", readAhead)
+		}
+		if received != 1<<20 {
+			t.Errorf("Expected the handler to receive the whole body, got %d bytes", received)
+		}
+	})
+
+	t.Run("Does not log incomplete bodies that would need redaction", func(t *testing.T) {
+		var entry BodyLogEntry
+		handler := BodyLoggingRouter(echo, BodyLogOptions{
+			LogFunc:        func(e BodyLogEntry) { entry = e },
+			RedactedFields: []string{"password"},
+			MaxBodySize:    10,
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/users", strings.NewReader(`{"password":"secret"}`)))
+
+		if strings.Contains(entry.ResponseBody, "secret") {
+			t.Errorf("Expected the password not to be logged, got '%s'", entry.ResponseBody)
+		}
+	})
+}
+
+// countingReader counts the bytes read from Reader
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.read += n
+	return n, err
+}
@@ -0,0 +1,130 @@
+package restapi
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BreakerOptions configures CircuitBreakerRouter
+type BreakerOptions struct {
+	// FailureThreshold is the share of failed requests within Window, between 0 and 1,
+	// at which the circuit opens. Defaults to 0.5.
+	FailureThreshold float64
+	// MinRequests is the number of requests within Window needed before the circuit can open,
+	// so that a single failure doesn't open it. Defaults to 10.
+	MinRequests int
+	// Window is the period over which failures are counted. Defaults to 10 seconds.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open before a trial request is let through.
+	// Defaults to 30 seconds.
+	Cooldown time.Duration
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker tracks the failures of the requests passing through CircuitBreakerRouter
+type circuitBreaker struct {
+	opts        BreakerOptions
+	mu          sync.Mutex
+	state       breakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+}
+
+// allow reports whether a request may pass and, if not, how long until the circuit half-opens
+func (b *circuitBreaker) allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if remaining := b.openedAt.Add(b.opts.Cooldown).Sub(now); remaining > 0 {
+			return false, remaining
+		}
+		// let a single trial request through
+		b.state = breakerHalfOpen
+		return true, 0
+	case breakerHalfOpen:
+		return false, b.opts.Cooldown
+	}
+	if now.Sub(b.windowStart) >= b.opts.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	return true, 0
+}
+
+// record records the outcome of a request that was allowed through
+func (b *circuitBreaker) record(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		if failed {
+			b.state, b.openedAt = breakerOpen, now
+		} else {
+			b.state, b.windowStart, b.requests, b.failures = breakerClosed, now, 0, 0
+		}
+		return
+	}
+	if b.state != breakerClosed {
+		return
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.opts.MinRequests && float64(b.failures)/float64(b.requests) >= b.opts.FailureThreshold {
+		b.state, b.openedAt = breakerOpen, now
+	}
+}
+
+// CircuitBreakerRouter is a middleware that protects a failing downstream dependency of next. Responses
+// with a 5xx status, and panics, count as failures. When the share of failures within the window reaches
+// the threshold, the circuit opens and requests get 503 Service Unavailable with a Retry-After header
+// without reaching next. After the cooldown a single trial request is let through, which closes the
+// circuit if it succeeds and opens it again otherwise.
+func CircuitBreakerRouter(next http.Handler, opts BreakerOptions) http.Handler {
+	return circuitBreakerRouter(next, opts, time.Now)
+}
+
+// circuitBreakerRouter is CircuitBreakerRouter with the clock used for the window and cooldown
+func circuitBreakerRouter(next http.Handler, opts BreakerOptions, now func() time.Time) http.Handler {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 0.5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	breaker := &circuitBreaker{opts: opts, windowStart: now()}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := breaker.allow(now())
+		if !allowed {
+			seconds := int((retryAfter + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			WriteError(w, NewHTTPError(http.StatusServiceUnavailable, ""))
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		completed := false
+		defer func() {
+			breaker.record(!completed || sw.status >= 500, now())
+		}()
+		next.ServeHTTP(sw, r)
+		completed = true
+	})
+}
@@ -0,0 +1,78 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerRouter(t *testing.T) {
+	failing := true
+	calls := 0
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	handler := circuitBreakerRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), BreakerOptions{FailureThreshold: 0.5, MinRequests: 4, Window: time.Minute, Cooldown: 30 * time.Second}, func() time.Time { return clock })
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/upstream", nil))
+		return w
+	}
+
+	for i := 0; i < 4; i++ {
+		if w := serve(); w.Code != http.StatusBadGateway {
+			t.Fatalf("Expected status 502 before the circuit opens, got %d", w.Code)
+		}
+	}
+
+	t.Run("Short-circuits while open", func(t *testing.T) {
+		w := serve()
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", w.Code)
+		}
+		if w.Header().Get("Retry-After") != "30" {
+			t.Errorf("Expected Retry-After '30', got '%s'", w.Header().Get("Retry-After"))
+		}
+		if calls != 4 {
+			t.Errorf("Expected the handler not to be called, got %d calls", calls)
+		}
+	})
+
+	t.Run("Stays open until the cooldown has passed", func(t *testing.T) {
+		clock = clock.Add(29 * time.Second)
+		w := serve()
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", w.Code)
+		}
+		if w.Header().Get("Retry-After") != "1" {
+			t.Errorf("Expected Retry-After '1', got '%s'", w.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("Reopens after a failed trial request", func(t *testing.T) {
+		clock = clock.Add(time.Second)
+		if w := serve(); w.Code != http.StatusBadGateway {
+			t.Errorf("Expected the trial request to reach the handler, got %d", w.Code)
+		}
+		if w := serve(); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503 after a failed trial, got %d", w.Code)
+		}
+	})
+
+	t.Run("Closes after a successful trial request", func(t *testing.T) {
+		failing = false
+		clock = clock.Add(30 * time.Second)
+		for i := 0; i < 3; i++ {
+			if w := serve(); w.Code != http.StatusOK {
+				t.Errorf("Expected status 200 once closed, got %d", w.Code)
+			}
+		}
+	})
+}
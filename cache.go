@@ -0,0 +1,84 @@
+package restapi
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedResponse is a response stored by CacheRouter
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// CacheRouter is a middleware that caches responses of next to GET requests in memory for ttl.
+// Requests with the same key, by default the request URI, are served from the cache until the
+// response expires. Only 200 responses without Set-Cookie are cached, streaming responses and
+// responses with Cache-Control private or no-store are not. keyFunc must include everything the
+// response varies by, e.g. the user for personalized data. Without keyFunc, requests carrying an
+// Authorization or Cookie header bypass the cache, so that one user's response isn't served to another.
+func CacheRouter(next http.Handler, ttl time.Duration, keyFunc func(*http.Request) string) http.Handler {
+	return cacheRouter(next, ttl, keyFunc, time.Now)
+}
+
+// cacheRouter is CacheRouter with the clock used for expiry
+func cacheRouter(next http.Handler, ttl time.Duration, keyFunc func(*http.Request) string, now func() time.Time) http.Handler {
+	// the default key doesn't tell users apart, so their requests are not cached
+	bypassCredentials := keyFunc == nil
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string {
+			return r.URL.RequestURI()
+		}
+	}
+	var mu sync.Mutex
+	cache := map[string]*cachedResponse{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || (bypassCredentials && (r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := keyFunc(r)
+		requestTime := now()
+
+		mu.Lock()
+		cached, ok := cache[key]
+		mu.Unlock()
+		if ok && requestTime.Before(cached.expires) {
+			replayResponse(w, &BufferedResponse{Status: cached.status, Header: cached.header, Body: cached.body})
+			return
+		}
+
+		response := serveBuffered(next, w, r)
+		if response == nil || response.Status != http.StatusOK || w.Header().Get("Set-Cookie") != "" || !cacheControlAllowsStoring(w.Header()) {
+			return
+		}
+		mu.Lock()
+		for cachedKey, entry := range cache {
+			if !requestTime.Before(entry.expires) {
+				delete(cache, cachedKey)
+			}
+		}
+		cache[key] = &cachedResponse{status: response.Status, header: response.Header, body: slices.Clone(response.Body), expires: requestTime.Add(ttl)}
+		mu.Unlock()
+	})
+}
+
+// cacheControlAllowsStoring reports whether the Cache-Control of a response allows a shared cache
+// to store it, i.e. it has neither the private nor the no-store directive
+func cacheControlAllowsStoring(header http.Header) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(name, "private") || strings.EqualFold(name, "no-store") {
+				return false
+			}
+		}
+	}
+	return true
+}
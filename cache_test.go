@@ -0,0 +1,119 @@
+package restapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheRouter(t *testing.T) {
+	calls := 0
+	handler := CacheRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/session":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		case "/private":
+			w.Header().Set("Cache-Control", "max-age=60, private")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "call %d", calls)
+	}), time.Minute, nil)
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		expectedBody  string
+		expectedCalls int
+	}{
+		{"Caches a GET response", "GET", "/items", "call 1", 1},
+		{"Serves the cached response", "GET", "/items", "call 1", 1},
+		{"Keys by the request URI", "GET", "/items?page=2", "call 2", 2},
+		{"Doesn't cache other methods", "POST", "/items", "call 3", 3},
+		{"Doesn't cache non-200 responses", "GET", "/missing", "call 4", 4},
+		{"Serves non-200 responses fresh", "GET", "/missing", "call 5", 5},
+		{"Doesn't cache responses with cookies", "GET", "/session", "call 6", 6},
+		{"Serves responses with cookies fresh", "GET", "/session", "call 7", 7},
+		{"Doesn't cache private responses", "GET", "/private", "call 8", 8},
+		{"Serves private responses fresh", "GET", "/private", "call 9", 9},
+		{"Doesn't cache no-store responses", "GET", "/no-store", "call 10", 10},
+		{"Serves no-store responses fresh", "GET", "/no-store", "call 11", 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if w.Header().Get("Content-Type") != "text/plain" {
+				t.Errorf("Expected Content-Type text/plain, got %q", w.Header().Get("Content-Type"))
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("Expected %d handler calls, got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestCacheRouterCredentials(t *testing.T) {
+	handler := CacheRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "user=%s", r.Header.Get("Authorization")+r.Header.Get("Cookie"))
+	}), time.Minute, nil)
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected string
+	}{
+		{"Authorization", "Authorization", "alice", "user=alice"},
+		{"Other Authorization", "Authorization", "bob", "user=bob"},
+		{"Cookie", "Cookie", "session=carol", "user=session=carol"},
+		{"Other Cookie", "Cookie", "session=dave", "user=session=dave"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected body %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCacheRouterExpiry(t *testing.T) {
+	calls := 0
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	handler := cacheRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("ok"))
+	}), time.Minute, func(r *http.Request) string { return "constant" }, func() time.Time { return clock })
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+	if calls != 1 {
+		t.Errorf("Expected 1 handler call with a shared key, got %d", calls)
+	}
+	clock = clock.Add(59 * time.Second)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	if calls != 1 {
+		t.Errorf("Expected the response to be cached until it expires, got %d handler calls", calls)
+	}
+	clock = clock.Add(time.Second)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	if calls != 2 {
+		t.Errorf("Expected 2 handler calls after expiry, got %d", calls)
+	}
+}
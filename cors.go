@@ -6,17 +6,28 @@ import (
 	"strings"
 )
 
+// defaultCORSHeaders are the headers allowed when no AllowedHeaders are configured
+const defaultCORSHeaders = "Content-Type, Authorization"
+
 // CORSConfig is a configuration struct for the CORS middleware
 type CORSConfig struct {
 	// AllowedOrigins is a list of origins allowed to make requests
 	// Use ["*"] to allow all origins (not recommended for production with credentials)
 	AllowedOrigins []string
+	// AllowOriginFunc decides per request whether an origin is allowed, e.g. by looking up
+	// tenant origins from a database. When set, it takes precedence over AllowedOrigins
+	// and allowed origins are reflected.
+	AllowOriginFunc func(origin string) bool
 	// AllowedMethods is a list of HTTP methods allowed in the request
 	AllowedMethods []string
 	// AllowedHeaders is a list of headers allowed in the request
 	AllowedHeaders []string
 	// AllowCredentials is a boolean that determines if credentials are allowed in the request
 	AllowCredentials bool
+	// CredentialOrigins is a list of origins for which credentials are allowed even when
+	// AllowCredentials is false, e.g. first-party origins among otherwise anonymous ones.
+	// The matched origin is reflected instead of "*" for these origins.
+	CredentialOrigins []string
 	// MaxAge is the maximum age for preflight requests (in seconds)
 	MaxAge int
 }
@@ -27,8 +38,12 @@ func (config *CORSConfig) HandleCORS(w http.ResponseWriter, r *http.Request) {
 	allowedOrigin := ""
 	originHeaderMissing := requestOrigin == ""
 
-	// Check if the request origin is in the allowed origins list
-	if len(config.AllowedOrigins) > 0 {
+	// Check if the request origin is allowed by the origin function or in the allowed origins list
+	if config.AllowOriginFunc != nil {
+		if !originHeaderMissing && config.AllowOriginFunc(requestOrigin) {
+			allowedOrigin = requestOrigin
+		}
+	} else if len(config.AllowedOrigins) > 0 {
 		for _, origin := range config.AllowedOrigins {
 			if origin == "*" {
 				allowedOrigin = "*"
@@ -70,14 +85,14 @@ func (config *CORSConfig) HandleCORS(w http.ResponseWriter, r *http.Request) {
 		if len(config.AllowedMethods) > 0 {
 			w.Header().Set("Access-Control-Allow-Methods", strings.Join(config.AllowedMethods, ","))
 		} else {
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(defaultCORSMethods, ", "))
 		}
 
 		// Handle Headers
 		if len(config.AllowedHeaders) > 0 {
 			w.Header().Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeaders, ","))
 		} else {
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", defaultCORSHeaders)
 		}
 	}
 
@@ -97,9 +112,13 @@ func (config *CORSConfig) HandleCORS(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 			}
+		} else if !originHeaderMissing && allowedOrigin != "" && config.allowsCredentialsFor(requestOrigin) {
+			// Credentials are allowed for this specific origin, which must be reflected instead of "*"
+			w.Header().Set("Access-Control-Allow-Origin", requestOrigin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
 		} else {
 			// Always set credentials to false when:
-			// 1. AllowCredentials is false, OR
+			// 1. AllowCredentials is false and the origin is not in CredentialOrigins, OR
 			// 2. Origin header is missing (security best practice)
 			w.Header().Set("Access-Control-Allow-Credentials", "false")
 
@@ -114,4 +133,64 @@ func (config *CORSConfig) HandleCORS(w http.ResponseWriter, r *http.Request) {
 	if shouldSetCORSHeaders && config.MaxAge > 0 && r.Method == "OPTIONS" {
 		w.Header().Set("Access-Control-Max-Age", fmt.Sprintf("%d", config.MaxAge))
 	}
+
+	// Unless all origins are allowed, the response depends on the Origin header whether it is
+	// accepted or rejected, which caches must know
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); (origin != "" && origin != "*") || !config.allowsAnyOrigin() {
+		addVary(w, "Origin")
+	}
+}
+
+// allowsAnyOrigin reports whether the response is the same for every origin, i.e. there is
+// no allow-list or it is the wildcard
+func (config *CORSConfig) allowsAnyOrigin() bool {
+	if config.AllowOriginFunc != nil {
+		return false
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return len(config.AllowedOrigins) == 0
+}
+
+// addVary adds field to the Vary header unless it is already listed
+func addVary(w http.ResponseWriter, field string) {
+	for _, value := range w.Header().Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+	w.Header().Add("Vary", field)
+}
+
+// applyDefaultCORS applies the default CORS policy of routers without a CORSConfig: any origin
+// is allowed without credentials. Like HandleCORS, the headers are set for requests without an
+// Origin header only when corsAlwaysOn is enabled.
+func applyDefaultCORS(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Origin") == "" && !corsAlwaysOn {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(defaultCORSMethods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", defaultCORSHeaders)
+	w.Header().Set("Access-Control-Allow-Credentials", "false")
+}
+
+// allowsCredentialsFor reports whether origin is listed in CredentialOrigins
+func (config *CORSConfig) allowsCredentialsFor(origin string) bool {
+	for _, credentialOrigin := range config.CredentialOrigins {
+		if credentialOrigin == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreflightRequest reports whether r is a CORS preflight request
+func isPreflightRequest(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
 }
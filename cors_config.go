@@ -6,6 +6,9 @@ var (
 	// true: Always set CORS headers (developer-friendly, non-spec-compliant)
 	// false: Only set CORS headers when Origin header is present (spec-compliant)
 	corsAlwaysOn = false
+
+	// defaultCORSMethods are the methods allowed when no AllowedMethods are configured
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 )
 
 // SetCORSAlwaysOn configures whether CORS headers should always be set, even without Origin header
@@ -27,3 +30,15 @@ func SetCORSAlwaysOn(alwaysOn bool) {
 func GetCORSAlwaysOn() bool {
 	return corsAlwaysOn
 }
+
+// SetDefaultCORSMethods sets the methods sent in Access-Control-Allow-Methods when no
+// CORSConfig.AllowedMethods are configured, including by the default CORS policy.
+// Defaults to GET, POST, PUT, PATCH, DELETE and OPTIONS.
+func SetDefaultCORSMethods(methods []string) {
+	defaultCORSMethods = methods
+}
+
+// GetDefaultCORSMethods returns the methods set with SetDefaultCORSMethods
+func GetDefaultCORSMethods() []string {
+	return defaultCORSMethods
+}
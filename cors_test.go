@@ -3,6 +3,7 @@ package restapi
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -163,3 +164,142 @@ func TestCORSLogic(t *testing.T) {
 		t.Logf("OPTIONS request handled with status: %d, Max-Age: %s", w.Code, maxAge)
 	})
 }
+
+func TestCORSCredentialOrigins(t *testing.T) {
+	router := &Router{
+		CORSConfig: &CORSConfig{
+			AllowedOrigins:    []string{"*"},
+			CredentialOrigins: []string{"https://app.example.com"},
+		},
+	}
+	router.HandleFunc("GET", "/test", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{"https://app.example.com", "https://app.example.com", "true"},
+		{"https://third-party.com", "*", "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != tt.allowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin '%s', got '%s'", tt.allowOrigin, origin)
+			}
+			if credentials := w.Header().Get("Access-Control-Allow-Credentials"); credentials != tt.credentials {
+				t.Errorf("Expected Access-Control-Allow-Credentials '%s', got '%s'", tt.credentials, credentials)
+			}
+		})
+	}
+
+	t.Run("Credential origin must also be an allowed origin", func(t *testing.T) {
+		router := &Router{
+			CORSConfig: &CORSConfig{
+				AllowedOrigins:    []string{"https://other.example.com"},
+				CredentialOrigins: []string{"https://app.example.com"},
+			},
+		}
+		router.HandleFunc("GET", "/test", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if credentials := w.Header().Get("Access-Control-Allow-Credentials"); credentials == "true" {
+			t.Error("Credentials should not be allowed for an origin that is not allowed")
+		}
+	})
+}
+
+func TestCORSAllowOriginFunc(t *testing.T) {
+	tenantOrigins := map[string]bool{"https://tenant-a.com": true}
+	router := &Router{
+		CORSConfig: &CORSConfig{
+			AllowedOrigins: []string{"https://static.com"},
+			AllowOriginFunc: func(origin string) bool {
+				return tenantOrigins[origin]
+			},
+			AllowCredentials: true,
+		},
+	}
+	router.HandleFunc("GET", "/test", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		origin   string
+		expected string
+	}{
+		{"https://tenant-a.com", "https://tenant-a.com"},
+		{"https://tenant-b.com", ""},
+		// the function takes precedence over the static list
+		{"https://static.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != tt.expected {
+				t.Errorf("Expected Access-Control-Allow-Origin '%s', got '%s'", tt.expected, origin)
+			}
+		})
+	}
+
+	// origins added at runtime are picked up without reconfiguring
+	tenantOrigins["https://tenant-b.com"] = true
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://tenant-b.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://tenant-b.com" {
+		t.Errorf("Expected newly added tenant origin to be allowed, got '%s'", origin)
+	}
+	if credentials := w.Header().Get("Access-Control-Allow-Credentials"); credentials != "true" {
+		t.Errorf("Expected credentials 'true', got '%s'", credentials)
+	}
+}
+
+func TestCORSVaryOrigin(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       *CORSConfig
+		origin       string
+		existingVary string
+		expected     []string
+	}{
+		{"Reflected origin", &CORSConfig{AllowedOrigins: []string{"https://app.com"}}, "https://app.com", "", []string{"Origin"}},
+		{"Appends to existing Vary", &CORSConfig{AllowedOrigins: []string{"https://app.com"}}, "https://app.com", "Accept-Encoding", []string{"Accept-Encoding", "Origin"}},
+		{"Not duplicated", &CORSConfig{AllowedOrigins: []string{"https://app.com"}}, "https://app.com", "origin", []string{"origin"}},
+		{"Wildcard origin", &CORSConfig{AllowedOrigins: []string{"*"}}, "https://app.com", "", nil},
+		{"Rejected origin", &CORSConfig{AllowedOrigins: []string{"https://app.com"}}, "https://other.com", "", []string{"Origin"}},
+		{"Rejected by origin function", &CORSConfig{AllowOriginFunc: func(string) bool { return false }}, "https://other.com", "", []string{"Origin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			if tt.existingVary != "" {
+				w.Header().Set("Vary", tt.existingVary)
+			}
+			tt.config.HandleCORS(w, req)
+
+			if vary := w.Header().Values("Vary"); !reflect.DeepEqual(vary, tt.expected) {
+				t.Errorf("Expected Vary %v, got %v", tt.expected, vary)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package restapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// CSRFOptions configures CSRFRouter
+type CSRFOptions struct {
+	// CookieName is the name of the cookie holding the token. Defaults to "csrf_token".
+	CookieName string
+	// HeaderName is the request header that must repeat the token. Defaults to "X-CSRF-Token".
+	HeaderName string
+	// Path is the path of the cookie. Defaults to "/".
+	Path string
+	// Secure restricts the cookie to HTTPS
+	Secure bool
+	// SameSite is the SameSite attribute of the cookie. Defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+}
+
+var contextKeyCSRFToken = contextKey("csrfToken")
+
+// CSRFRouter is a middleware that protects cookie-authenticated routes against cross-site request
+// forgery with the double-submit cookie pattern. It issues a random token in a cookie readable by
+// scripts, and requests with unsafe methods (POST, PUT, PATCH, DELETE) must send the same token in
+// opts.HeaderName or get 403. Safe methods pass through. Handlers can read the token with
+// GetCSRFToken, e.g. to embed it in a page. Cross-origin clients need the header in the
+// AllowedHeaders of the CORSConfig.
+func CSRFRouter(next http.Handler, opts CSRFOptions) http.Handler {
+	if opts.CookieName == "" {
+		opts.CookieName = "csrf_token"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cookie, err := r.Cookie(opts.CookieName); err == nil {
+			token = cookie.Value
+		}
+		issued := token == ""
+		if issued {
+			token = newCSRFToken()
+			http.SetCookie(w, &http.Cookie{
+				Name:     opts.CookieName,
+				Value:    token,
+				Path:     opts.Path,
+				Secure:   opts.Secure,
+				SameSite: opts.SameSite,
+			})
+		}
+
+		switch r.Method {
+		case "POST", "PUT", "PATCH", "DELETE":
+			header := r.Header.Get(opts.HeaderName)
+			if issued || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+				WriteError(w, NewHTTPError(http.StatusForbidden, "invalid CSRF token"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyCSRFToken, token)))
+	})
+}
+
+// GetCSRFToken returns the CSRF token set by CSRFRouter, or an empty string
+func GetCSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(contextKeyCSRFToken).(string)
+	return token
+}
+
+// newCSRFToken returns a random token
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,60 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFRouter(t *testing.T) {
+	var tokenInHandler string
+	handler := CSRFRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenInHandler = GetCSRFToken(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}), CSRFOptions{})
+
+	t.Run("Issues a token on safe requests", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != "csrf_token" || cookies[0].Value == "" {
+			t.Fatalf("Expected a csrf_token cookie, got %v", cookies)
+		}
+		if tokenInHandler != cookies[0].Value {
+			t.Errorf("Expected the handler to see token %q, got %q", cookies[0].Value, tokenInHandler)
+		}
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		cookie         string
+		header         string
+		expectedStatus int
+	}{
+		{"Accepts a matching token", "POST", "abc", "abc", http.StatusOK},
+		{"Rejects a mismatching token", "DELETE", "abc", "xyz", http.StatusForbidden},
+		{"Rejects a missing header", "PUT", "abc", "", http.StatusForbidden},
+		{"Rejects a missing cookie", "PATCH", "", "abc", http.StatusForbidden},
+		{"Passes safe methods without a header", "GET", "abc", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/form", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// RegisterDebugRoutes mounts the net/http/pprof profiling endpoints under basePath, e.g.
+// "/debug/pprof/heap". The routes are protected, so they are served only through the router's
+// authorization and permission middlewares and respond with 500 when those aren't set.
+func RegisterDebugRoutes(router *Router, basePath string) {
+	basePath = strings.TrimRight(basePath, "/")
+	for _, name := range []string{"heap", "goroutine", "allocs", "block", "mutex", "threadcreate"} {
+		router.HandleProtectedFunc("GET", basePath+"/"+name, nil, debugHandler(pprof.Handler(name)))
+	}
+	router.HandleProtectedFunc("GET", basePath+"/profile", nil, debugHandler(http.HandlerFunc(pprof.Profile)))
+	router.HandleProtectedFunc("GET", basePath+"/trace", nil, debugHandler(http.HandlerFunc(pprof.Trace)))
+	router.HandleProtectedFunc("GET", basePath+"/cmdline", nil, debugHandler(http.HandlerFunc(pprof.Cmdline)))
+	router.HandleProtectedFunc("GET", basePath+"/symbol", nil, debugHandler(http.HandlerFunc(pprof.Symbol)))
+}
+
+// debugHandler adapts a pprof handler to a RouteHandlerFunc
+func debugHandler(handler http.Handler) RouteHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		handler.ServeHTTP(w, r)
+	}
+}
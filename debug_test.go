@@ -0,0 +1,58 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterDebugRoutes(t *testing.T) {
+	t.Run("Fails closed without authorization", func(t *testing.T) {
+		router := &Router{}
+		RegisterDebugRoutes(router, "/debug/pprof")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/heap", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", w.Code)
+		}
+	})
+
+	t.Run("Serves profiles to authorized requests", func(t *testing.T) {
+		router := &Router{
+			AuthorizationMiddleware: func(routeContext *RouteContext, handler http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Authorization") != "Bearer admin" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					handler.ServeHTTP(w, r)
+				})
+			},
+			PermissionMiddleware: func(routeContext *RouteContext, handler http.Handler) http.Handler {
+				return handler
+			},
+		}
+		RegisterDebugRoutes(router, "/debug/pprof/")
+
+		tests := []struct {
+			name           string
+			authorization  string
+			expectedStatus int
+		}{
+			{"Unauthorized", "", http.StatusUnauthorized},
+			{"Authorized", "Bearer admin", http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest("GET", "/debug/pprof/goroutine?debug=1", nil)
+				req.Header.Set("Authorization", tt.authorization)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != tt.expectedStatus {
+					t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+				}
+			})
+		}
+	})
+}
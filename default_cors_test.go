@@ -127,3 +127,47 @@ func TestDefaultCORSRespectsGlobalSetting(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultCORSMethods(t *testing.T) {
+	originalMethods := GetDefaultCORSMethods()
+	defer SetDefaultCORSMethods(originalMethods)
+
+	router := &Router{BasePath: "/api"}
+	router.HandleFunc("GET", "/test", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	multiRouter, err := NewMultiRouter("/v1", []*Router{router})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configured := &Router{BasePath: "/api", CORSConfig: &CORSConfig{AllowedOrigins: []string{"*"}}}
+	configured.HandleFunc("GET", "/test", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		path    string
+	}{
+		{"Router default policy", router, "/api/test"},
+		{"MultiRouter default policy", multiRouter, "/v1/api/test"},
+		{"CORSConfig without AllowedMethods", configured, "/api/test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDefaultCORSMethods(originalMethods)
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Origin", "https://example.com")
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, req)
+			if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET, POST, PUT, PATCH, DELETE, OPTIONS" {
+				t.Errorf("Expected default methods including PATCH, got '%s'", methods)
+			}
+
+			SetDefaultCORSMethods([]string{"GET", "OPTIONS"})
+			w = httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, req)
+			if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET, OPTIONS" {
+				t.Errorf("Expected customized methods 'GET, OPTIONS', got '%s'", methods)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package restapi
+
+var currentEnvironment string
+
+// SetEnvironment sets the environment the application runs in, e.g. "production" or "staging".
+// Routes registered with EnabledEnvironments are only served in one of those environments.
+func SetEnvironment(environment string) {
+	currentEnvironment = environment
+}
+
+// GetEnvironment returns the environment set with SetEnvironment
+func GetEnvironment() string {
+	return currentEnvironment
+}
+
+// enabledInCurrentEnvironment reports whether the route is served in the current environment.
+// Routes without EnabledEnvironments are served in every environment.
+func (route *Route) enabledInCurrentEnvironment() bool {
+	if len(route.EnabledEnvironments) == 0 {
+		return true
+	}
+	for _, environment := range route.EnabledEnvironments {
+		if environment == currentEnvironment {
+			return true
+		}
+	}
+	return false
+}
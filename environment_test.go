@@ -0,0 +1,43 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnabledEnvironments(t *testing.T) {
+	originalEnvironment := GetEnvironment()
+	defer SetEnvironment(originalEnvironment)
+
+	router := &Router{}
+	router.HandleFuncForEnvironments("POST", "/seed", []string{"staging", "development"}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		WriteNoContent(w)
+	})
+	router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		WriteNoContent(w)
+	})
+
+	tests := []struct {
+		name        string
+		environment string
+		method      string
+		path        string
+		expected    int
+	}{
+		{"Staging-only route is reachable in staging", "staging", "POST", "/seed", http.StatusNoContent},
+		{"Staging-only route returns 404 in production", "production", "POST", "/seed", http.StatusNotFound},
+		{"Staging-only route returns 404 without an environment", "", "POST", "/seed", http.StatusNotFound},
+		{"Regular route is reachable in production", "production", "GET", "/users", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetEnvironment(tt.environment)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// HTTPError is an error carrying the HTTP status code and message to respond with
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// NewHTTPError creates an HTTPError. An empty message defaults to the status text.
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message}
+}
+
+type ErrorResponse struct {
+	Timestamp int64  `json:"timestamp"`
+	Error     string `json:"error"`
+}
+
+// MarshalJSON encodes the timestamp in the format set with SetTimestampFormat, like Response
+func (response ErrorResponse) MarshalJSON() ([]byte, error) {
+	type plainErrorResponse ErrorResponse
+	if timestampFormat == "" {
+		return json.Marshal(plainErrorResponse(response))
+	}
+	return json.Marshal(struct {
+		Timestamp string `json:"timestamp"`
+		Error     string `json:"error"`
+	}{time.Unix(response.Timestamp, 0).UTC().Format(timestampFormat), response.Error})
+}
+
+func getDefaultJSONErrorResponse(status int, message string) interface{} {
+	return ErrorResponse{
+		Timestamp: time.Now().Unix(),
+		Error:     message,
+	}
+}
+
+var jsonErrorFormatter func(status int, message string) interface{} = getDefaultJSONErrorResponse
+
+// SetJSONErrorFormatter sets the function used to build the JSON body of error responses
+func SetJSONErrorFormatter(f func(status int, message string) interface{}) {
+	jsonErrorFormatter = f
+}
+
+type errorStatus struct {
+	err    error
+	status int
+}
+
+var errorStatuses = []errorStatus{}
+
+// RegisterErrorStatus maps err, and errors wrapping it, to an HTTP status code
+func RegisterErrorStatus(err error, status int) {
+	errorStatuses = append(errorStatuses, errorStatus{err, status})
+}
+
+// lookupErrorStatus returns the status for err and whether err is an HTTPError or a registered error
+func lookupErrorStatus(err error) (int, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status, true
+	}
+	for _, mapping := range errorStatuses {
+		if errors.Is(err, mapping.err) {
+			return mapping.status, true
+		}
+	}
+	return http.StatusInternalServerError, false
+}
+
+// StatusForError returns the HTTP status for err. HTTPErrors use their own status, registered
+// errors their mapped status and all other errors 500.
+func StatusForError(err error) int {
+	status, _ := lookupErrorStatus(err)
+	return status
+}
+
+// WriteError writes err as a JSON error response with the status from StatusForError.
+// The message of unmapped errors is replaced with the status text so that internal details are not leaked.
+func WriteError(w http.ResponseWriter, err error) error {
+	status, mapped := lookupErrorStatus(err)
+	return writeErrorResponse(w, err, status, mapped)
+}
+
+func writeErrorResponse(w http.ResponseWriter, err error, status int, exposeMessage bool) error {
+	message := http.StatusText(status)
+	if exposeMessage {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			message = httpErr.Message
+		} else {
+			message = err.Error()
+		}
+	}
+	sw := &statusWriter{ResponseWriter: w}
+	sw.Header().Set("Content-Type", "application/json")
+	sw.WriteHeader(status)
+	return json.NewEncoder(sw).Encode(jsonErrorFormatter(status, message))
+}
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	// Type is a URI identifying the problem type, "about:blank" when empty
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type, defaulting to the status text
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code, 500 when zero
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI identifying this occurrence of the problem
+	Instance string `json:"instance,omitempty"`
+}
+
+// WriteProblem writes p as an application/problem+json response with the status of p
+func WriteProblem(w http.ResponseWriter, p Problem) error {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	sw := &statusWriter{ResponseWriter: w}
+	sw.Header().Set("Content-Type", "application/problem+json")
+	sw.WriteHeader(p.Status)
+	return json.NewEncoder(sw).Encode(p)
+}
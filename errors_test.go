@@ -0,0 +1,114 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+func TestErrorStatusMapping(t *testing.T) {
+	originalStatuses := errorStatuses
+	defer func() { errorStatuses = originalStatuses }()
+	RegisterErrorStatus(errUserNotFound, http.StatusNotFound)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"Registered error", errUserNotFound, http.StatusNotFound},
+		{"Wrapped registered error", fmt.Errorf("loading profile: %w", errUserNotFound), http.StatusNotFound},
+		{"HTTPError", NewHTTPError(http.StatusConflict, ""), http.StatusConflict},
+		{"Unmapped error", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := StatusForError(tt.err); status != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, status)
+			}
+		})
+	}
+
+	t.Run("WriteError writes the mapped status and message", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		WriteError(w, fmt.Errorf("loading profile: %w", errUserNotFound))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+		var response ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != "loading profile: user not found" {
+			t.Errorf("Unexpected error message '%s'", response.Error)
+		}
+	})
+
+	t.Run("WriteError hides the message of unmapped errors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		WriteError(w, errors.New("connection to 10.0.0.5 refused"))
+
+		var response ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != "Internal Server Error" {
+			t.Errorf("Expected generic message, got '%s'", response.Error)
+		}
+	})
+
+	t.Run("Custom error formatter", func(t *testing.T) {
+		defer SetJSONErrorFormatter(getDefaultJSONErrorResponse)
+		SetJSONErrorFormatter(func(status int, message string) interface{} {
+			return map[string]interface{}{"code": status, "message": message}
+		})
+		w := httptest.NewRecorder()
+		WriteError(w, errUserNotFound)
+
+		if body := w.Body.String(); body != "{\"code\":404,\"message\":\"user not found\"}\n" {
+			t.Errorf("Unexpected body %s", body)
+		}
+	})
+}
+
+func TestWriteProblem(t *testing.T) {
+	tests := []struct {
+		name           string
+		problem        Problem
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			"All members",
+			Problem{Type: "https://example.com/probs/out-of-credit", Title: "You do not have enough credit.", Status: http.StatusForbidden, Detail: "Your current balance is 30, but that costs 50.", Instance: "/account/12345/msgs/abc"},
+			http.StatusForbidden,
+			`{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","status":403,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc"}`,
+		},
+		{"Title defaults to the status text", Problem{Status: http.StatusNotFound}, http.StatusNotFound, `{"title":"Not Found","status":404}`},
+		{"Status defaults to 500", Problem{Detail: "boom"}, http.StatusInternalServerError, `{"title":"Internal Server Error","status":500,"detail":"boom"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WriteProblem(w, tt.problem); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("Expected Content-Type application/problem+json, got '%s'", contentType)
+			}
+			if body := w.Body.String(); body != tt.expectedBody+"\n" {
+				t.Errorf("Expected body %s, got %s", tt.expectedBody, body)
+			}
+		})
+	}
+}
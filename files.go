@@ -0,0 +1,243 @@
+package restapi
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeDownload serves the file at filePath as an attachment named filename.
+// It supports Range requests for resuming downloads and sets Accept-Ranges and Last-Modified.
+func ServeDownload(w http.ResponseWriter, r *http.Request, filePath, filename string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	ServeReadSeeker(w, r, filename, info.ModTime(), file)
+}
+
+// ServeReadSeeker serves content from any io.ReadSeeker, e.g. an object storage reader or a bytes.Reader.
+// It supports Range requests and the conditional request headers based on modTime, which is ignored if zero.
+// The Content-Type is derived from the extension of name or the content unless already set.
+//
+// Compressible content such as text, JSON or subtitles is gzip encoded for clients accepting it.
+// Range requests are always served uncompressed, as byte ranges refer to the unencoded content.
+func ServeReadSeeker(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, content io.ReadSeeker) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if isCompressible(contentType) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodGet && r.Header.Get("Range") == "" && acceptsEncoding(r, "gzip") {
+			gw := &gzipFileWriter{ResponseWriter: w}
+			defer gw.Close()
+			w = gw
+		}
+	}
+	// ServeContent handles Range, Last-Modified and the conditional request headers
+	http.ServeContent(w, r, name, modTime, &contextReadSeeker{ReadSeeker: content, ctx: r.Context()})
+}
+
+// contextReadSeeker stops reading once ctx is done, e.g. when the client aborts a download or a
+// video seek, so that serving stops promptly and the caller can release the content
+type contextReadSeeker struct {
+	io.ReadSeeker
+	ctx context.Context
+}
+
+func (rs *contextReadSeeker) Read(p []byte) (int, error) {
+	if err := rs.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return rs.ReadSeeker.Read(p)
+}
+
+// ServeContentRange serves content of contentType like ServeReadSeeker, for content without a file
+// name, e.g. generated in memory. It answers Range requests with 206 Partial Content.
+func ServeContentRange(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, contentType string, modTime time.Time) {
+	w.Header().Set("Content-Type", contentType)
+	ServeReadSeeker(w, r, "", modTime, content)
+}
+
+// SPAFallbackHandler returns a handler for a wildcard route such as "/*path" that serves the file
+// at the captured path under rootDir, or indexFile, e.g. "index.html", if there is no such file,
+// so that client-side routing of a single-page app works. Other routes take precedence over the
+// wildcard route. Paths can't escape rootDir.
+func SPAFallbackHandler(rootDir, indexFile string) RouteHandlerFunc {
+	root := http.Dir(rootDir)
+	return func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		file, info, err := openFile(root, ctx.wildcardPath)
+		if err == nil && info.IsDir() {
+			file.Close()
+			err = os.ErrNotExist
+		}
+		if err != nil {
+			if file, info, err = openFile(root, indexFile); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			if info.IsDir() {
+				file.Close()
+				http.NotFound(w, r)
+				return
+			}
+		}
+		defer file.Close()
+		ServeReadSeeker(w, r, info.Name(), info.ModTime(), file)
+	}
+}
+
+// ServeFiles registers a GET route serving the files under dir at urlPath, e.g. "/static".
+// Requests for a directory serve its index.html, missing files get 404 and paths can't escape dir.
+func (router *Router) ServeFiles(urlPath, dir string) {
+	root := http.Dir(dir)
+	router.HandleFunc("GET", strings.TrimRight(urlPath, "/")+"/*filepath", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		name := ctx.wildcardPath
+		file, info, err := openFile(root, name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if info.IsDir() {
+			file.Close()
+			if name != "" && !strings.HasSuffix(name, "/") {
+				// relative links in the index resolve against the directory only with a trailing slash
+				target := path.Base(r.URL.Path) + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				// a relative Location, as the router may be mounted under a stripped prefix
+				w.Header().Set("Location", target)
+				w.WriteHeader(http.StatusMovedPermanently)
+				return
+			}
+			if file, info, err = openFile(root, path.Join(name, "index.html")); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			if info.IsDir() {
+				file.Close()
+				http.NotFound(w, r)
+				return
+			}
+		}
+		defer file.Close()
+		ServeReadSeeker(w, r, info.Name(), info.ModTime(), file)
+	})
+}
+
+// openFile opens name under root. Names are cleaned, so they can't escape root.
+func openFile(root http.Dir, name string) (http.File, os.FileInfo, error) {
+	file, err := root.Open(path.Clean("/" + name))
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// isCompressible reports whether content of contentType benefits from compression
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of r allows encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) && strings.TrimSpace(name) != "*" {
+			continue
+		}
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil || quality <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipFileWriter compresses successful full responses. Other statuses, such as 304 or 416, pass through.
+type gzipFileWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipFileWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	if status == http.StatusOK {
+		gw.Header().Del("Content-Length")
+		gw.Header().Set("Content-Encoding", "gzip")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipFileWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+func (gw *gzipFileWriter) Close() error {
+	if gw.gz != nil {
+		return gw.gz.Close()
+	}
+	return nil
+}
+
+func (gw *gzipFileWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
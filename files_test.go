@@ -0,0 +1,346 @@
+package restapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeDownload(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(filePath, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Ranged resume request carries the disposition header", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/download", nil)
+		req.Header.Set("Range", "bytes=4-")
+		w := httptest.NewRecorder()
+		ServeDownload(w, req, filePath, "report.txt")
+
+		if w.Code != http.StatusPartialContent {
+			t.Errorf("Expected status 206, got %d", w.Code)
+		}
+		if body := w.Body.String(); body != "456789" {
+			t.Errorf("Expected body '456789', got '%s'", body)
+		}
+		if disposition := w.Header().Get("Content-Disposition"); disposition != "attachment; filename=report.txt" {
+			t.Errorf("Expected attachment disposition, got '%s'", disposition)
+		}
+		if acceptRanges := w.Header().Get("Accept-Ranges"); acceptRanges != "bytes" {
+			t.Errorf("Expected Accept-Ranges 'bytes', got '%s'", acceptRanges)
+		}
+		if w.Header().Get("Last-Modified") == "" {
+			t.Error("Expected Last-Modified header to be set")
+		}
+	})
+
+	t.Run("Non-ASCII filename is encoded", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/download", nil)
+		w := httptest.NewRecorder()
+		ServeDownload(w, req, filePath, "raportti-ä.txt")
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if disposition := w.Header().Get("Content-Disposition"); disposition != "attachment; filename*=utf-8''raportti-%C3%A4.txt" {
+			t.Errorf("Expected RFC 2231 encoded filename, got '%s'", disposition)
+		}
+	})
+
+	t.Run("Unchanged file returns 304", func(t *testing.T) {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/download", nil)
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		ServeDownload(w, req, filePath, "report.txt")
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("Expected status 304, got %d", w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body, got '%s'", w.Body.String())
+		}
+		if w.Header().Get("Last-Modified") == "" {
+			t.Error("Expected Last-Modified header to be set")
+		}
+	})
+
+	t.Run("Missing file returns 404", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/download", nil)
+		w := httptest.NewRecorder()
+		ServeDownload(w, req, filepath.Join(dir, "missing.txt"), "missing.txt")
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestServeReadSeeker(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	content := []byte("hello from object storage")
+
+	t.Run("Full fetch", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/objects/greeting.txt", nil)
+		w := httptest.NewRecorder()
+		ServeReadSeeker(w, req, "greeting.txt", modTime, bytes.NewReader(content))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if w.Body.String() != string(content) {
+			t.Errorf("Expected full content, got '%s'", w.Body.String())
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+			t.Errorf("Expected Content-Type from the extension, got '%s'", contentType)
+		}
+		if lastModified := w.Header().Get("Last-Modified"); lastModified != modTime.Format(http.TimeFormat) {
+			t.Errorf("Expected Last-Modified '%s', got '%s'", modTime.Format(http.TimeFormat), lastModified)
+		}
+	})
+
+	t.Run("Range request", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/objects/greeting.txt", nil)
+		req.Header.Set("Range", "bytes=0-4")
+		w := httptest.NewRecorder()
+		ServeReadSeeker(w, req, "greeting.txt", modTime, bytes.NewReader(content))
+
+		if w.Code != http.StatusPartialContent {
+			t.Errorf("Expected status 206, got %d", w.Code)
+		}
+		if w.Body.String() != "hello" {
+			t.Errorf("Expected 'hello', got '%s'", w.Body.String())
+		}
+		if contentRange := w.Header().Get("Content-Range"); contentRange != "bytes 0-4/25" {
+			t.Errorf("Expected Content-Range 'bytes 0-4/25', got '%s'", contentRange)
+		}
+	})
+	t.Run("Compresses text for clients accepting gzip", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/subtitles/en.vtt", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		w := httptest.NewRecorder()
+		ServeReadSeeker(w, req, "en.txt", modTime, bytes.NewReader(content))
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+			t.Fatalf("Expected Content-Encoding 'gzip', got '%s'", encoding)
+		}
+		if w.Header().Get("Content-Length") != "" {
+			t.Error("Expected no Content-Length for the compressed body")
+		}
+		reader, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, _ := io.ReadAll(reader)
+		if string(decompressed) != string(content) {
+			t.Errorf("Expected decompressed content, got '%s'", decompressed)
+		}
+	})
+
+	t.Run("Range request is not compressed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/objects/greeting.txt", nil)
+		req.Header.Set("Range", "bytes=0-4")
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		ServeReadSeeker(w, req, "greeting.txt", modTime, bytes.NewReader(content))
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("Expected no Content-Encoding, got '%s'", encoding)
+		}
+		if w.Body.String() != "hello" {
+			t.Errorf("Expected 'hello', got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("Binary media is not compressed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/videos/clip.mp4", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		ServeReadSeeker(w, req, "clip.mp4", modTime, bytes.NewReader(content))
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("Expected no Content-Encoding, got '%s'", encoding)
+		}
+	})
+
+	t.Run("Explicitly refused gzip is not used", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/objects/greeting.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip;q=0")
+		w := httptest.NewRecorder()
+		ServeReadSeeker(w, req, "greeting.txt", modTime, bytes.NewReader(content))
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("Expected no Content-Encoding, got '%s'", encoding)
+		}
+	})
+}
+
+func TestSPAFallbackHandler(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "assets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join(filepath.Dir(dir), "secret.txt"))
+
+	router := &Router{}
+	router.HandleFunc("GET", "/api/status", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.Write([]byte("ok"))
+	})
+	router.HandleFunc("GET", "/*path", SPAFallbackHandler(dir, "index.html"))
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedBody string
+	}{
+		{"Serves existing files", "/assets/app.js", "console.log(1)"},
+		{"Serves the index for client-side routes", "/settings/profile", "<html>app</html>"},
+		{"Serves the index for directories", "/assets/", "<html>app</html>"},
+		{"Serves the index for the root path", "/", "<html>app</html>"},
+		{"Doesn't escape the root directory", "/../secret.txt", "<html>app</html>"},
+		{"API routes take precedence", "/api/status", "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL.Path = tt.path
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouterServeFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "index.html"), []byte("<h1>docs</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join(filepath.Dir(dir), "secret.txt"))
+
+	router := &Router{}
+	router.ServeFiles("/static/", dir)
+
+	tests := []struct {
+		name             string
+		path             string
+		expectedStatus   int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{"Serves a file", "/static/app.css", http.StatusOK, "body{}", ""},
+		{"Serves the index of a directory", "/static/docs/", http.StatusOK, "<h1>docs</h1>", ""},
+		{"Redirects a directory without a trailing slash", "/static/docs", http.StatusMovedPermanently, "", "docs/"},
+		{"Missing file returns 404", "/static/missing.css", http.StatusNotFound, "", ""},
+		{"Directory without an index returns 404", "/static/docs/empty/", http.StatusNotFound, "", ""},
+		{"Doesn't escape the directory", "/static/../secret.txt", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL.Path = tt.path
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if location := w.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Expected Location %q, got %q", tt.expectedLocation, location)
+			}
+		})
+	}
+}
+
+func TestServeContentRange(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := httptest.NewRequest("GET", "/export", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	ServeContentRange(w, req, bytes.NewReader([]byte("0123456789")), "application/octet-stream", modTime)
+
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("Expected status 206, got %d", w.Code)
+	}
+	if body := w.Body.String(); body != "2345" {
+		t.Errorf("Expected body '2345', got '%s'", body)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/octet-stream" {
+		t.Errorf("Expected Content-Type 'application/octet-stream', got '%s'", contentType)
+	}
+	if contentRange := w.Header().Get("Content-Range"); contentRange != "bytes 2-5/10" {
+		t.Errorf("Expected Content-Range 'bytes 2-5/10', got '%s'", contentRange)
+	}
+	if lastModified := w.Header().Get("Last-Modified"); lastModified != modTime.Format(http.TimeFormat) {
+		t.Errorf("Expected Last-Modified '%s', got '%s'", modTime.Format(http.TimeFormat), lastModified)
+	}
+}
+
+// countingReadSeeker counts the bytes read from the underlying ReadSeeker
+type countingReadSeeker struct {
+	io.ReadSeeker
+	read int
+}
+
+func (rs *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := rs.ReadSeeker.Read(p)
+	rs.read += n
+	return n, err
+}
+
+func TestServeReadSeekerStopsOnCancellation(t *testing.T) {
+	content := &countingReadSeeker{ReadSeeker: bytes.NewReader(bytes.Repeat([]byte("a"), 1<<20))}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/video.mp4", nil).WithContext(ctx)
+	req.Header.Set("Range", "bytes=1024-")
+	w := httptest.NewRecorder()
+	ServeReadSeeker(w, req, "video.mp4", time.Time{}, content)
+
+	if content.read != 0 {
+		t.Errorf("Expected no content to be read after cancellation, got %d bytes", content.read)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %d bytes", w.Body.Len())
+	}
+}
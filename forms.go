@@ -0,0 +1,148 @@
+package restapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+// ReadForm parses a form, e.g. an application/x-www-form-urlencoded body, and sets the fields of the
+// struct v points to from the values named by their `form` tags, e.g. `form:"email"`. Values from the
+// body take precedence over the query string. Fields of the types supported by BindParams can be
+// bound, as can slices of them, e.g. for multiple checkboxes. Booleans, also in slices, accept "on".
+// Missing values leave fields unchanged. Malformed values result in a 400 HTTPError.
+func ReadForm(r *http.Request, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a non-nil pointer to a struct")
+	}
+	if err := r.ParseForm(); err != nil {
+		return formParseError(err)
+	}
+	target = target.Elem()
+	for i := 0; i < target.NumField(); i++ {
+		name := target.Type().Field(i).Tag.Get("form")
+		values := r.Form[name]
+		if name == "" || len(values) == 0 {
+			continue
+		}
+		field := target.Field(i)
+		var err error
+		if field.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+			for j, value := range values {
+				if err = setFormField(slice.Index(j), value); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				field.Set(slice)
+			}
+		} else {
+			err = setFormField(field, values[0])
+		}
+		if err != nil {
+			if errors.Is(err, strconv.ErrSyntax) || errors.Is(err, strconv.ErrRange) {
+				return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid value for form field %s", name))
+			}
+			return fmt.Errorf("form field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFormField sets field from a form value like setFieldFromString, also accepting "on" for booleans
+func setFormField(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Bool && value == "on" {
+		// the value HTML checkboxes send by default
+		field.SetBool(true)
+		return nil
+	}
+	return setFieldFromString(field, value)
+}
+
+// formParseError converts an error parsing a form body into an HTTPError: 413 for a body that is
+// too large and 400 otherwise. Errors storing uploaded files are returned as is.
+func formParseError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || errors.Is(err, multipart.ErrMessageTooLarge) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, "")
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return err
+	}
+	return NewHTTPError(http.StatusBadRequest, err.Error())
+}
+
+// MultipartData is a parsed multipart/form-data request body
+type MultipartData struct {
+	// Fields holds the values of the non-file fields
+	Fields map[string][]string
+	// Files holds the headers of the uploaded files by field name
+	Files map[string][]*multipart.FileHeader
+
+	form *multipart.Form
+}
+
+// ReadMultipart parses a multipart/form-data request body. Up to maxMemory bytes of file parts are
+// kept in memory, the rest is stored in temporary files, which RemoveAll deletes. A malformed body
+// results in a 400 HTTPError, one exceeding a BodyLimitRouter or the memory limit in a 413 HTTPError.
+func ReadMultipart(r *http.Request, maxMemory int64) (*MultipartData, error) {
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return nil, formParseError(err)
+	}
+	return &MultipartData{Fields: r.MultipartForm.Value, Files: r.MultipartForm.File, form: r.MultipartForm}, nil
+}
+
+// Value returns the first value of the field name, or an empty string
+func (data *MultipartData) Value(name string) string {
+	if values := data.Fields[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// File opens the first file uploaded as name. It returns http.ErrMissingFile if there is none.
+// The caller must close the file.
+func (data *MultipartData) File(name string) (multipart.File, *multipart.FileHeader, error) {
+	headers := data.Files[name]
+	if len(headers) == 0 {
+		return nil, nil, http.ErrMissingFile
+	}
+	file, err := headers[0].Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, headers[0], nil
+}
+
+// SaveFile writes the first file uploaded as name to dest, replacing an existing file.
+// dest must not be derived from the uploaded file name without sanitizing it.
+func (data *MultipartData) SaveFile(name, dest string) error {
+	file, _, err := data.File(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// RemoveAll removes the temporary files of the uploads
+func (data *MultipartData) RemoveAll() error {
+	return data.form.RemoveAll()
+}
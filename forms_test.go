@@ -0,0 +1,142 @@
+package restapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMultipart(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("title", "Holiday")
+	part, _ := writer.CreateFormFile("photo", "beach.jpg")
+	part.Write([]byte("jpeg data"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/uploads", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	data, err := ReadMultipart(req, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.RemoveAll()
+
+	if title := data.Value("title"); title != "Holiday" {
+		t.Errorf("Expected title 'Holiday', got '%s'", title)
+	}
+
+	t.Run("File", func(t *testing.T) {
+		file, header, err := data.File("photo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		if header.Filename != "beach.jpg" || string(content) != "jpeg data" {
+			t.Errorf("Expected beach.jpg with 'jpeg data', got %s with '%s'", header.Filename, content)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		if _, _, err := data.File("avatar"); !errors.Is(err, http.ErrMissingFile) {
+			t.Errorf("Expected http.ErrMissingFile, got %v", err)
+		}
+	})
+
+	t.Run("SaveFile", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "photo.jpg")
+		if err := data.SaveFile("photo", dest); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(dest)
+		if err != nil || string(content) != "jpeg data" {
+			t.Errorf("Expected the saved file to contain 'jpeg data', got '%s' (%v)", content, err)
+		}
+	})
+
+	t.Run("Not multipart", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/uploads", strings.NewReader(`{"title":"Holiday"}`))
+		req.Header.Set("Content-Type", "application/json")
+		_, err := ReadMultipart(req, 1<<20)
+		if status := StatusForError(err); status != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d (%v)", http.StatusBadRequest, status, err)
+		}
+	})
+
+	t.Run("Body too large", func(t *testing.T) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("title", strings.Repeat("a", 1024))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/uploads", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 100)
+		_, err := ReadMultipart(req, 1<<20)
+		if status := StatusForError(err); status != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d, got %d (%v)", http.StatusRequestEntityTooLarge, status, err)
+		}
+	})
+}
+
+func TestReadForm(t *testing.T) {
+	type signupForm struct {
+		Email     string   `form:"email"`
+		Age       int      `form:"age"`
+		Subscribe bool     `form:"subscribe"`
+		Topics    []string `form:"topic"`
+		Consents  []bool   `form:"consent"`
+		Source    string   `form:"source"`
+		Internal  string
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		expected       signupForm
+		expectedStatus int
+	}{
+		{
+			"Binds all fields",
+			"?source=ad",
+			"email=john%40example.com&age=42&subscribe=on&topic=go&topic=http",
+			signupForm{Email: "john@example.com", Age: 42, Subscribe: true, Topics: []string{"go", "http"}, Source: "ad"},
+			0,
+		},
+		{"Checkbox slices accept on", "", "consent=on&consent=false", signupForm{Consents: []bool{true, false}}, 0},
+		{"Missing values are left unchanged", "", "email=john%40example.com", signupForm{Email: "john@example.com"}, 0},
+		{"Body takes precedence over the query", "?email=query%40example.com", "email=body%40example.com", signupForm{Email: "body@example.com"}, 0},
+		{"Malformed value", "", "age=old", signupForm{}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/signup"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			var form signupForm
+			err := ReadForm(req, &form)
+			if tt.expectedStatus != 0 {
+				if status := StatusForError(err); status != tt.expectedStatus {
+					t.Errorf("Expected status %d, got %d (%v)", tt.expectedStatus, status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(form, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, form)
+			}
+		})
+	}
+}
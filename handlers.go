@@ -0,0 +1,50 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// JSONHandler creates a RouteHandlerFunc that decodes the JSON request body into In, calls fn
+// and writes the returned Out with WriteJSON. An empty request body leaves In as its zero value.
+// A malformed body results in 400, errors returned by fn are written with WriteError.
+func JSONHandler[In any, Out any](fn func(context.Context, In, *RouteContext) (Out, error)) RouteHandlerFunc {
+	return jsonHandler(fn, false)
+}
+
+// HandleFuncTyped registers a route on router that works like JSONHandler and declares In and Out
+// as its request and response types. The request body is validated against In: unknown fields
+// and values of the wrong type result in 400. The declared types are recorded on the Route as
+// RequestType and ResponseType so that documentation can be generated from them.
+func HandleFuncTyped[In any, Out any](router *Router, method, path string, fn func(context.Context, In, *RouteContext) (Out, error)) {
+	router.HandleFunc(method, path, jsonHandler(fn, true))
+	route := &router.Routes[len(router.Routes)-1]
+	route.RequestType = reflect.TypeOf((*In)(nil)).Elem()
+	route.ResponseType = reflect.TypeOf((*Out)(nil)).Elem()
+}
+
+func jsonHandler[In any, Out any](fn func(context.Context, In, *RouteContext) (Out, error), disallowUnknownFields bool) RouteHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, routeContext *RouteContext) {
+		var in In
+		if r.Body != nil {
+			decoder := json.NewDecoder(r.Body)
+			if disallowUnknownFields {
+				decoder.DisallowUnknownFields()
+			}
+			if err := decoder.Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+				WriteError(w, NewHTTPError(http.StatusBadRequest, "Invalid JSON: "+err.Error()))
+				return
+			}
+		}
+		out, err := fn(r.Context(), in, routeContext)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		WriteJSON(w, out)
+	}
+}
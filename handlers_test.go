@@ -0,0 +1,120 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type createUserRequest struct {
+	Name string `json:"name"`
+}
+
+type createUserResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestJSONHandler(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("POST", "/users/:id", JSONHandler(func(ctx context.Context, in createUserRequest, rc *RouteContext) (createUserResponse, error) {
+		if in.Name == "" {
+			return createUserResponse{}, NewHTTPError(http.StatusUnprocessableEntity, "name is required")
+		}
+		if in.Name == "fail" {
+			return createUserResponse{}, errors.New("database is down")
+		}
+		id, _ := rc.Params.Get("id")
+		return createUserResponse{ID: id, Name: in.Name}, nil
+	}))
+
+	t.Run("Decodes input and encodes output", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users/7", strings.NewReader(`{"name":"John"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var response struct {
+			Data createUserResponse `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data != (createUserResponse{ID: "7", Name: "John"}) {
+			t.Errorf("Unexpected response data: %+v", response.Data)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Malformed JSON returns 400", `{"name":`, http.StatusBadRequest},
+		{"HTTPError status is used", `{"name":""}`, http.StatusUnprocessableEntity},
+		{"Empty body decodes to zero value", ``, http.StatusUnprocessableEntity},
+		{"Other errors return 500", `{"name":"fail"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users/7", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if strings.Contains(w.Body.String(), "database is down") {
+				t.Error("Internal error message should not be leaked")
+			}
+		})
+	}
+}
+
+func TestHandleFuncTyped(t *testing.T) {
+	router := &Router{BasePath: "/api"}
+	HandleFuncTyped(router, "POST", "/users", func(ctx context.Context, in createUserRequest, rc *RouteContext) (createUserResponse, error) {
+		return createUserResponse{ID: "1", Name: in.Name}, nil
+	})
+
+	t.Run("Records the declared types on the route", func(t *testing.T) {
+		route := router.Routes[0]
+		if route.RelativePath != "/api/users" {
+			t.Errorf("Expected path '/api/users', got '%s'", route.RelativePath)
+		}
+		if route.RequestType != reflect.TypeOf(createUserRequest{}) {
+			t.Errorf("Expected request type createUserRequest, got %v", route.RequestType)
+		}
+		if route.ResponseType != reflect.TypeOf(createUserResponse{}) {
+			t.Errorf("Expected response type createUserResponse, got %v", route.ResponseType)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Body matching the declared type", `{"name":"John"}`, http.StatusOK},
+		{"Unknown field returns 400", `{"name":"John","admin":true}`, http.StatusBadRequest},
+		{"Wrong field type returns 400", `{"name":42}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/users", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
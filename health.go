@@ -0,0 +1,27 @@
+package restapi
+
+import (
+	"net/http"
+)
+
+// RegisterHealthRoutes adds a liveness endpoint at /healthz that always responds with 200 and
+// a readiness endpoint at /readyz that runs checks and responds with the status of each of
+// them, with 200 when all pass and 503 Service Unavailable when any fails. A failed check is reported
+// as "not ready" and its error is logged, so that internal details don't reach clients.
+func RegisterHealthRoutes(router *Router, checks map[string]func() error) {
+	router.HandleFunc("GET", "/healthz", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		WriteJSON(w, map[string]string{"status": "ok"})
+	})
+	router.HandleFunc("GET", "/readyz", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		statuses := make(map[string]string, len(checks))
+		for name, check := range checks {
+			statuses[name] = "ok"
+			if err := check(); err != nil {
+				statuses[name] = "not ready"
+				ctx.Logger().Printf("readiness check %s failed: %v", name, err)
+				ctx.SetStatus(http.StatusServiceUnavailable)
+			}
+		}
+		WriteJSON(w, statuses)
+	})
+}
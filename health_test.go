@@ -0,0 +1,61 @@
+package restapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegisterHealthRoutes(t *testing.T) {
+	var output bytes.Buffer
+	originalOutput := log.Writer()
+	log.SetOutput(&output)
+	defer log.SetOutput(originalOutput)
+
+	var databaseErr error
+	router := &Router{}
+	RegisterHealthRoutes(router, map[string]func() error{
+		"database": func() error { return databaseErr },
+		"cache":    func() error { return nil },
+	})
+
+	tests := []struct {
+		name             string
+		path             string
+		databaseErr      error
+		expectedStatus   int
+		expectedDatabase string
+	}{
+		{"Liveness", "/healthz", errors.New("connection refused"), http.StatusOK, ""},
+		{"Ready", "/readyz", nil, http.StatusOK, "ok"},
+		{"Not ready", "/readyz", errors.New("connection refused"), http.StatusServiceUnavailable, "not ready"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			databaseErr = tt.databaseErr
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			var response struct {
+				Data map[string]string `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Expected JSON body, got error %v", err)
+			}
+			if tt.expectedDatabase != "" && response.Data["database"] != tt.expectedDatabase {
+				t.Errorf("Expected database status %q, got %q", tt.expectedDatabase, response.Data["database"])
+			}
+			if tt.expectedStatus == http.StatusServiceUnavailable && !strings.Contains(output.String(), "readiness check database failed: connection refused") {
+				t.Errorf("Expected the check error to be logged, got %q", output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package restapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// IdempotencyStore stores the responses recorded by IdempotencyRouter, e.g. in memory or Redis.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the response stored for key, if it hasn't expired
+	Get(key string) (*BufferedResponse, bool)
+	// Set stores response for key for ttl
+	Set(key string, response *BufferedResponse, ttl time.Duration)
+}
+
+// memoryIdempotencyStore is an IdempotencyStore keeping responses in memory
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*cachedResponse
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore keeping responses in memory, which suits
+// a single instance. Expired responses are removed when new ones are stored.
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{responses: map[string]*cachedResponse{}}
+}
+
+func (s *memoryIdempotencyStore) Get(key string) (*BufferedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.responses[key]
+	if !ok || !time.Now().Before(stored.expires) {
+		return nil, false
+	}
+	return &BufferedResponse{Status: stored.status, Header: stored.header.Clone(), Body: slices.Clone(stored.body)}, true
+}
+
+func (s *memoryIdempotencyStore) Set(key string, response *BufferedResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for storedKey, stored := range s.responses {
+		if !now.Before(stored.expires) {
+			delete(s.responses, storedKey)
+		}
+	}
+	s.responses[key] = &cachedResponse{status: response.Status, header: response.Header.Clone(), body: slices.Clone(response.Body), expires: now.Add(ttl)}
+}
+
+// IdempotencyRouter is a middleware that makes requests with an Idempotency-Key header safe to
+// retry, e.g. payments. The first response for a key, method, path and caller is stored for ttl and
+// replayed with an Idempotent-Replayed header to later requests with the same key. A request arriving
+// while one with the same key is still being served gets 409 Conflict. Server errors and streaming
+// responses are not stored, so those requests can be retried. A nil store keeps responses in memory.
+//
+// callerFunc identifies the caller, so that a client can't have another client's response replayed
+// by guessing its key. By default it is a hash of the Authorization header. Callers without one
+// share their keys, so pass e.g. the user ID of a session cookie or ClientIP for other schemes.
+func IdempotencyRouter(next http.Handler, store IdempotencyStore, ttl time.Duration, callerFunc func(*http.Request) string) http.Handler {
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	if callerFunc == nil {
+		callerFunc = func(r *http.Request) string {
+			sum := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+			return hex.EncodeToString(sum[:])
+		}
+	}
+	var mu sync.Mutex
+	inFlight := map[string]bool{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if idempotencyKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.Method + " " + r.URL.Path + " " + callerFunc(r) + " " + idempotencyKey
+
+		mu.Lock()
+		if inFlight[key] {
+			mu.Unlock()
+			WriteError(w, NewHTTPError(http.StatusConflict, "a request with this Idempotency-Key is in progress"))
+			return
+		}
+		if stored, ok := store.Get(key); ok {
+			mu.Unlock()
+			w.Header().Set("Idempotent-Replayed", "true")
+			replayResponse(w, stored)
+			return
+		}
+		inFlight[key] = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(inFlight, key)
+			mu.Unlock()
+		}()
+
+		response := serveBuffered(next, w, r)
+		if response == nil || response.Status >= http.StatusInternalServerError {
+			return
+		}
+		store.Set(key, response, ttl)
+	})
+}
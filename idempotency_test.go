@@ -0,0 +1,120 @@
+package restapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyRouter(t *testing.T) {
+	calls := 0
+	handler := IdempotencyRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusBadGateway)
+		} else {
+			w.Header().Set("Location", "/payments/1")
+			w.WriteHeader(http.StatusCreated)
+		}
+		fmt.Fprintf(w, "call %d", calls)
+	}), nil, time.Minute, nil)
+
+	tests := []struct {
+		name             string
+		path             string
+		key              string
+		authorization    string
+		expectedStatus   int
+		expectedBody     string
+		expectedReplayed string
+		expectedCalls    int
+	}{
+		{"Serves the first request", "/payments", "abc", "Bearer alice", http.StatusCreated, "call 1", "", 1},
+		{"Replays the response", "/payments", "abc", "Bearer alice", http.StatusCreated, "call 1", "true", 1},
+		{"Serves other keys", "/payments", "def", "Bearer alice", http.StatusCreated, "call 2", "", 2},
+		{"Keys by the path", "/refunds", "abc", "Bearer alice", http.StatusCreated, "call 3", "", 3},
+		{"Serves requests without a key", "/payments", "", "Bearer alice", http.StatusCreated, "call 4", "", 4},
+		{"Scopes keys to the caller", "/payments", "abc", "Bearer bob", http.StatusCreated, "call 5", "", 5},
+		{"Doesn't store server errors", "/failing", "abc", "Bearer alice", http.StatusBadGateway, "call 6", "", 6},
+		{"Serves server errors again", "/failing", "abc", "Bearer alice", http.StatusBadGateway, "call 7", "", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+			req.Header.Set("Authorization", tt.authorization)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed"); replayed != tt.expectedReplayed {
+				t.Errorf("Expected Idempotent-Replayed %q, got %q", tt.expectedReplayed, replayed)
+			}
+			if tt.expectedStatus == http.StatusCreated && w.Header().Get("Location") != "/payments/1" {
+				t.Errorf("Expected the Location header, got %q", w.Header().Get("Location"))
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("Expected %d handler calls, got %d", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyRouterInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := IdempotencyRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}), NewMemoryIdempotencyStore(), time.Minute, ClientIP)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/payments", nil)
+		req.Header.Set("Idempotency-Key", "abc")
+		return req
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 while the first request is in progress, got %d", w.Code)
+	}
+	close(release)
+	<-done
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected the replayed 201 response, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	store.Set("expired", &BufferedResponse{Status: http.StatusOK}, -time.Second)
+	store.Set("fresh", &BufferedResponse{Status: http.StatusCreated}, time.Minute)
+
+	if _, ok := store.Get("expired"); ok {
+		t.Error("Expected the expired response to be gone")
+	}
+	if response, ok := store.Get("fresh"); !ok || response.Status != http.StatusCreated {
+		t.Errorf("Expected the fresh response, got %+v", response)
+	}
+}
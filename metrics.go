@@ -0,0 +1,60 @@
+package restapi
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsCollector receives an observation for every request served by a matched route.
+// Implement it to export metrics to any backend, e.g. Prometheus.
+type MetricsCollector interface {
+	// ObserveRequest is called after the handler returns, with the route template such as "/users/:id"
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// GaugeCollector can be implemented by a MetricsCollector to also receive gauges that help
+// to diagnose saturation. Each method is called with the new value whenever it changes.
+type GaugeCollector interface {
+	// SetInFlight receives the number of requests currently served by matched routes
+	SetInFlight(n int)
+	// SetQueueDepth receives the number of requests waiting for a slot in a ConcurrencyLimitRouter
+	SetQueueDepth(n int)
+}
+
+var metricsCollector MetricsCollector
+
+var (
+	inFlightGauge   atomic.Int64
+	queueDepthGauge atomic.Int64
+)
+
+// SetMetricsCollector sets the collector that routers report requests to. Use nil to disable metrics.
+func SetMetricsCollector(collector MetricsCollector) {
+	metricsCollector = collector
+}
+
+func observeRequest(method, route string, status int, duration time.Duration) {
+	if metricsCollector == nil {
+		return
+	}
+	// a handler that writes nothing results in an implicit 200
+	if status == 0 {
+		status = http.StatusOK
+	}
+	metricsCollector.ObserveRequest(method, route, status, duration)
+}
+
+func addInFlight(delta int64) {
+	n := inFlightGauge.Add(delta)
+	if collector, ok := metricsCollector.(GaugeCollector); ok {
+		collector.SetInFlight(int(n))
+	}
+}
+
+func addQueueDepth(delta int64) {
+	n := queueDepthGauge.Add(delta)
+	if collector, ok := metricsCollector.(GaugeCollector); ok {
+		collector.SetQueueDepth(int(n))
+	}
+}
@@ -0,0 +1,107 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	method   string
+	route    string
+	status   int
+	duration time.Duration
+}
+
+type testCollector struct {
+	observations []observation
+}
+
+func (c *testCollector) ObserveRequest(method, route string, status int, duration time.Duration) {
+	c.observations = append(c.observations, observation{method, route, status, duration})
+}
+
+func TestMetricsCollector(t *testing.T) {
+	collector := &testCollector{}
+	SetMetricsCollector(collector)
+	defer SetMetricsCollector(nil)
+
+	router := &Router{BasePath: "/api"}
+	router.HandleFunc("GET", "/users/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		time.Sleep(time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	router.HandleFunc("GET", "/health", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/api/health", "/api/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if len(collector.observations) != 3 {
+		t.Fatalf("Expected 3 observations for matched routes, got %d", len(collector.observations))
+	}
+	first := collector.observations[0]
+	if first.method != "GET" || first.route != "/api/users/:id" || first.status != http.StatusAccepted {
+		t.Errorf("Unexpected observation %+v", first)
+	}
+	if first.duration < time.Millisecond {
+		t.Errorf("Expected duration of at least 1ms, got %s", first.duration)
+	}
+	if collector.observations[1].route != "/api/users/:id" {
+		t.Errorf("Expected the route template to be reported, got '%s'", collector.observations[1].route)
+	}
+	if status := collector.observations[2].status; status != http.StatusOK {
+		t.Errorf("Expected implicit status 200, got %d", status)
+	}
+}
+
+type gaugeCollector struct {
+	testCollector
+	inFlight   chan int
+	queueDepth chan int
+}
+
+func (c *gaugeCollector) SetInFlight(n int)   { c.inFlight <- n }
+func (c *gaugeCollector) SetQueueDepth(n int) { c.queueDepth <- n }
+
+func TestGaugeCollector(t *testing.T) {
+	collector := &gaugeCollector{inFlight: make(chan int, 10), queueDepth: make(chan int, 10)}
+	SetMetricsCollector(collector)
+	defer SetMetricsCollector(nil)
+
+	release := make(chan struct{})
+	router := &Router{}
+	router.HandleFunc("GET", "/slow", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		<-release
+	})
+	handler := ConcurrencyLimitRouter(router, 1, true)
+
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+			done <- struct{}{}
+		}()
+	}
+
+	if n := <-collector.inFlight; n != 1 {
+		t.Errorf("Expected 1 request in flight while the handler is blocked, got %d", n)
+	}
+	if n := <-collector.queueDepth; n != 1 {
+		t.Errorf("Expected 1 queued request, got %d", n)
+	}
+
+	close(release)
+	<-done
+	<-done
+	expected := []int{0, 1, 0}
+	for _, want := range expected {
+		if n := <-collector.inFlight; n != want {
+			t.Errorf("Expected in flight gauge %d, got %d", want, n)
+		}
+	}
+	if n := <-collector.queueDepth; n != 0 {
+		t.Errorf("Expected the queue to drain, got %d", n)
+	}
+}
@@ -1,8 +1,18 @@
 package restapi
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -12,17 +22,77 @@ type statusWriter struct {
 	http.ResponseWriter
 	status      int
 	wroteHeader bool
+	// routeContext is set on the writer the router passes to route handlers
+	routeContext *RouteContext
 }
 
 // WriteHeader is a wrapper around the ResponseWriter's WriteHeader method that stores the status code
 func (sw *statusWriter) WriteHeader(statusCode int) {
 	if !sw.wroteHeader {
+		if sw.routeContext != nil {
+			writeWarningHeaders(sw.Header(), sw.routeContext)
+		}
 		sw.status = statusCode
 		sw.ResponseWriter.WriteHeader(statusCode)
 		sw.wroteHeader = true
 	}
 }
 
+// Write is a wrapper around the ResponseWriter's Write method that records the implicit 200 status
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// routeContextFromWriter returns the RouteContext of the route handler the ResponseWriter was passed to, if any
+func routeContextFromWriter(w http.ResponseWriter) *RouteContext {
+	for {
+		if sw, ok := w.(*statusWriter); ok && sw.routeContext != nil {
+			return sw.routeContext
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter so that http.ResponseController can reach it
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Flush is a wrapper around the ResponseWriter's Flush method so that streaming responses work through middlewares
+func (sw *statusWriter) Flush() {
+	flushWriter(sw.ResponseWriter)
+}
+
+// canFlush reports whether the ResponseWriter underneath w supports flushing.
+// Wrappers are unwrapped first because they delegate Flush to the writer they wrap.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	switch w.(type) {
+	case http.Flusher, interface{ FlushError() error }:
+		return true
+	}
+	return false
+}
+
+// flushWriter flushes w, or the first ResponseWriter it wraps that supports flushing.
+// It returns false if flushing is not supported.
+func flushWriter(w http.ResponseWriter) bool {
+	return http.NewResponseController(w).Flush() == nil
+}
+
 type HttpLogEntry struct {
 	Method  string              `json:"method"`
 	Path    string              `json:"path"`
@@ -90,3 +160,362 @@ func TracingRouter(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+var contextKeyRequestID = contextKey("requestID")
+
+// maxRequestIDLength limits the length of an inbound X-Request-ID that is reused
+const maxRequestIDLength = 128
+
+// RequestIDRouter is a middleware that adds a request ID to the request context and the
+// X-Request-ID response header. A valid inbound X-Request-ID, e.g. from an upstream proxy,
+// is reused so that requests can be correlated across services. Otherwise a new ID is generated.
+func RequestIDRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !isValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		ctx := context.WithValue(r.Context(), contextKeyRequestID, requestID)
+		w.Header().Set("X-Request-ID", requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the request ID set by RequestIDRouter, or an empty string
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKeyRequestID).(string)
+	return requestID
+}
+
+// isValidRequestID reports whether an inbound request ID is safe to reuse in headers and logs
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && !strings.ContainsRune("-_.:", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// BodyLimitRouter is a middleware that limits the size of request bodies to maxBytes.
+// Requests declaring a larger Content-Length are rejected with 413 before the body is read,
+// bodies of unknown length (e.g. chunked) are capped with http.MaxBytesReader.
+func BodyLimitRouter(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			WriteError(w, NewHTTPError(http.StatusRequestEntityTooLarge, ""))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DecompressRouter is a middleware that transparently decompresses request bodies sent with
+// Content-Encoding: gzip, so that ReadJSON and other readers see the plain body. A malformed gzip
+// header is rejected with 400, later corruption makes reading the body fail with a 400 HTTPError.
+// Wrap BodyLimitRouter in it to limit the decompressed size.
+func DecompressRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			WriteError(w, NewHTTPError(http.StatusBadRequest, "malformed gzip request body"))
+			return
+		}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		r.Body = &gzipBody{reader: reader, body: r.Body}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gzipBody is a request body decompressed by DecompressRouter
+type gzipBody struct {
+	reader *gzip.Reader
+	body   io.ReadCloser
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = NewHTTPError(http.StatusBadRequest, "malformed gzip request body: "+err.Error())
+	}
+	return n, err
+}
+
+func (b *gzipBody) Close() error {
+	b.reader.Close()
+	return b.body.Close()
+}
+
+// ResponseTimeRouter is a middleware that sets the X-Response-Time header and an "app" Server-Timing
+// entry to the time next took until it wrote the response headers, in milliseconds.
+func ResponseTimeRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &timingWriter{ResponseWriter: w, start: time.Now()}
+		next.ServeHTTP(tw, r)
+		// for responses without a body written implicitly after next returns
+		tw.setTimingHeaders()
+	})
+}
+
+// timingWriter sets the response time headers of ResponseTimeRouter before the headers are written
+type timingWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *timingWriter) setTimingHeaders() {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	duration := strconv.FormatFloat(float64(time.Since(tw.start).Microseconds())/1000, 'f', 3, 64)
+	tw.Header().Set("X-Response-Time", duration+"ms")
+	tw.Header().Add("Server-Timing", "app;dur="+duration)
+}
+
+func (tw *timingWriter) WriteHeader(statusCode int) {
+	// informational responses such as 103 Early Hints are followed by the final headers
+	if statusCode >= http.StatusOK {
+		tw.setTimingHeaders()
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	tw.setTimingHeaders()
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func (tw *timingWriter) Flush() {
+	tw.setTimingHeaders()
+	flushWriter(tw.ResponseWriter)
+}
+
+// RequirePreconditionRouter is a middleware that rejects requests without an If-Match or
+// If-Unmodified-Since header with 428 Precondition Required, so that clients can't
+// overwrite changes they haven't seen. It applies to PUT, PATCH and DELETE by default.
+func RequirePreconditionRouter(next http.Handler, methods ...string) http.Handler {
+	if len(methods) == 0 {
+		methods = []string{"PUT", "PATCH", "DELETE"}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method && r.Header.Get("If-Match") == "" && r.Header.Get("If-Unmodified-Since") == "" {
+				WriteError(w, NewHTTPError(http.StatusPreconditionRequired, "If-Match or If-Unmodified-Since header is required"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var inFlightRequests atomic.Int64
+
+// InFlightRouter is a middleware that counts the requests currently being served by next.
+// The count is available from InFlightCount, e.g. to report not ready while draining.
+func InFlightRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlightCount returns the number of requests currently being served through InFlightRouter
+func InFlightCount() int {
+	return int(inFlightRequests.Load())
+}
+
+// ConcurrencyLimitRouter is a middleware that limits the number of requests served by next at the
+// same time to max, e.g. to protect a downstream dependency. When the limit is reached, requests wait
+// for a slot if wait is true, giving up when the request is canceled, and otherwise get
+// 503 Service Unavailable right away. Waiting requests are reported as the queue depth to a
+// GaugeCollector. It panics if max is less than 1.
+func ConcurrencyLimitRouter(next http.Handler, max int, wait bool) http.Handler {
+	if max < 1 {
+		panic(fmt.Sprintf("ConcurrencyLimitRouter: max must be at least 1, got %d", max))
+	}
+	slots := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if !wait {
+				WriteError(w, NewHTTPError(http.StatusServiceUnavailable, ""))
+				return
+			}
+			addQueueDepth(1)
+			select {
+			case slots <- struct{}{}:
+				addQueueDepth(-1)
+			case <-r.Context().Done():
+				addQueueDepth(-1)
+				WriteError(w, NewHTTPError(http.StatusServiceUnavailable, ""))
+				return
+			}
+		}
+		defer func() { <-slots }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+var panicHandler func(w http.ResponseWriter, r *http.Request, recovered interface{}, stack []byte)
+
+// SetPanicHandler sets the function RecoveryRouter calls when a handler panics. It receives the
+// recovered value and the stack trace and is responsible for writing the response.
+// When unset, a generic 500 response is written.
+func SetPanicHandler(handler func(w http.ResponseWriter, r *http.Request, recovered interface{}, stack []byte)) {
+	panicHandler = handler
+}
+
+// RecoveryRouter is a middleware that recovers from panics in the next handler
+func RecoveryRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// http.ErrAbortHandler is used to abort a response on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			if panicHandler != nil {
+				panicHandler(w, r, recovered, debug.Stack())
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BufferedResponse is a response captured by ResponseRouter before it is written
+type BufferedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// bufferedWriter buffers the status and body of a response until it is flushed. A handler calling
+// Flush is streaming, so the buffered response is written and buffering stops.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (bw *bufferedWriter) WriteHeader(statusCode int) {
+	if bw.streaming {
+		bw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if bw.status == 0 {
+		bw.status = statusCode
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.streaming {
+		return bw.ResponseWriter.Write(b)
+	}
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+func (bw *bufferedWriter) Flush() {
+	if !bw.streaming {
+		bw.streaming = true
+		if bw.status != 0 {
+			bw.ResponseWriter.WriteHeader(bw.status)
+		}
+		bw.ResponseWriter.Write(bw.body.Bytes())
+		bw.body.Reset()
+	}
+	flushWriter(bw.ResponseWriter)
+}
+
+// serveBuffered serves r with next through a bufferedWriter and writes the response to w. The returned
+// response has only the headers set by next, not those of the middlewares around it, so that it can
+// be replayed later. It is nil when next streamed the response.
+func serveBuffered(next http.Handler, w http.ResponseWriter, r *http.Request) *BufferedResponse {
+	before := w.Header().Clone()
+	bw := &bufferedWriter{ResponseWriter: w}
+	next.ServeHTTP(bw, r)
+	if bw.streaming {
+		return nil
+	}
+	status := bw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := bw.body.Bytes()
+	w.WriteHeader(status)
+	w.Write(body)
+
+	header := http.Header{}
+	for name, values := range w.Header() {
+		if !slices.Equal(values, before[name]) {
+			header[name] = slices.Clone(values)
+		}
+	}
+	return &BufferedResponse{Status: status, Header: header, Body: body}
+}
+
+// replayResponse writes a response recorded by serveBuffered to w
+func replayResponse(w http.ResponseWriter, response *BufferedResponse) {
+	for name, values := range response.Header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// ResponseRouter is a middleware that buffers the response of next and passes it to modify, which can
+// inspect and change the status, headers and body before the response is written.
+// Streaming responses, i.e. handlers that flush, bypass buffering and are not passed to modify.
+func ResponseRouter(next http.Handler, modify func(r *http.Request, response *BufferedResponse)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := &bufferedWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, r)
+		if bw.streaming {
+			return
+		}
+
+		status := bw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := &BufferedResponse{Status: status, Header: w.Header(), Body: bw.body.Bytes()}
+		modify(r, response)
+
+		if len(response.Body) > 0 {
+			response.Header.Set("Content-Length", strconv.Itoa(len(response.Body)))
+		}
+		w.WriteHeader(response.Status)
+		w.Write(response.Body)
+	})
+}
@@ -0,0 +1,450 @@
+package restapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBodyLimitRouter(t *testing.T) {
+	t.Run("Declared Content-Length over the limit is rejected before reading", func(t *testing.T) {
+		handlerCalled := false
+		handler := BodyLimitRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerCalled = true
+		}), 10)
+
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 100)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", w.Code)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got '%s'", contentType)
+		}
+		if handlerCalled {
+			t.Error("Handler should not be called when Content-Length exceeds the limit")
+		}
+	})
+
+	t.Run("Chunked body is capped by the reader", func(t *testing.T) {
+		var readErr error
+		handler := BodyLimitRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = io.ReadAll(r.Body)
+		}), 10)
+
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 100)))
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(readErr, &maxBytesErr) {
+			t.Errorf("Expected *http.MaxBytesError reading an oversized chunked body, got %v", readErr)
+		}
+	})
+
+	t.Run("Body within the limit is passed through", func(t *testing.T) {
+		var body []byte
+		handler := BodyLimitRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = io.ReadAll(r.Body)
+		}), 10)
+
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader("small"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if string(body) != "small" {
+			t.Errorf("Expected body 'small', got '%s'", body)
+		}
+	})
+}
+
+func TestDecompressRouter(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(`{"id":1,"name":"John Doe"}`))
+	writer.Close()
+	truncated := compressed.Bytes()[:compressed.Len()/2]
+
+	handler := DecompressRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user testUser
+		if err := ReadJSON(r, &user); err != nil {
+			WriteError(w, err)
+			return
+		}
+		WriteJSON(w, user.Name)
+	}))
+
+	tests := []struct {
+		name           string
+		body           []byte
+		encoding       string
+		expectedStatus int
+	}{
+		{"Gzip body is decompressed", compressed.Bytes(), "gzip", http.StatusOK},
+		{"Plain body is passed through", []byte(`{"id":1,"name":"John Doe"}`), "", http.StatusOK},
+		{"Malformed gzip header", []byte(`{"id":1}`), "gzip", http.StatusBadRequest},
+		{"Truncated gzip body", truncated, "gzip", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.Contains(w.Body.String(), "John Doe") {
+				t.Errorf("Expected the decoded name in the body, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestResponseTimeRouter(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"Written body", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			w.Write([]byte("ok"))
+		}},
+		{"Explicit status", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		}},
+		{"Implicit status", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+		}},
+		{"After early hints", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusEarlyHints)
+			time.Sleep(5 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ResponseTimeRouter(tt.handler).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			responseTime := w.Header().Get("X-Response-Time")
+			duration, err := time.ParseDuration(responseTime)
+			if err != nil || duration < 5*time.Millisecond {
+				t.Errorf("Expected X-Response-Time of at least 5ms, got '%s'", responseTime)
+			}
+			expectedTiming := "app;dur=" + strings.TrimSuffix(responseTime, "ms")
+			if timing := w.Header().Values("Server-Timing"); len(timing) != 1 || timing[0] != expectedTiming {
+				t.Errorf("Expected Server-Timing '%s', got %v", expectedTiming, timing)
+			}
+		})
+	}
+}
+
+func TestRecoveryRouter(t *testing.T) {
+	panickingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+
+	t.Run("Default recovery writes a generic 500", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		RecoveryRouter(panickingHandler).ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", w.Code)
+		}
+		if strings.Contains(w.Body.String(), "something went wrong") {
+			t.Error("Default recovery should not leak the panic value")
+		}
+	})
+
+	t.Run("Custom panic handler receives the recovered value and stack", func(t *testing.T) {
+		defer SetPanicHandler(nil)
+		var gotRecovered interface{}
+		var gotStack []byte
+		SetPanicHandler(func(w http.ResponseWriter, r *http.Request, recovered interface{}, stack []byte) {
+			gotRecovered = recovered
+			gotStack = stack
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		RecoveryRouter(panickingHandler).ServeHTTP(w, req)
+
+		if gotRecovered != "something went wrong" {
+			t.Errorf("Expected recovered value 'something went wrong', got %v", gotRecovered)
+		}
+		if !strings.Contains(string(gotStack), "TestRecoveryRouter") {
+			t.Errorf("Expected stack trace to contain the panicking test, got:\n%s", gotStack)
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected custom status 503, got %d", w.Code)
+		}
+	})
+}
+
+func TestResponseRouter(t *testing.T) {
+	addChecksum := func(r *http.Request, response *BufferedResponse) {
+		response.Body = bytes.ReplaceAll(response.Body, []byte("secret"), []byte("******"))
+		checksum := sha256.Sum256(response.Body)
+		response.Header.Set("X-Response-Checksum", hex.EncodeToString(checksum[:]))
+	}
+
+	t.Run("Rewrites the body and adds a checksum header", func(t *testing.T) {
+		handler := ResponseRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("password: secret"))
+		}), addChecksum)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", w.Code)
+		}
+		if body := w.Body.String(); body != "password: ******" {
+			t.Errorf("Expected masked body, got '%s'", body)
+		}
+		checksum := sha256.Sum256([]byte("password: ******"))
+		if header := w.Header().Get("X-Response-Checksum"); header != hex.EncodeToString(checksum[:]) {
+			t.Errorf("Unexpected checksum header '%s'", header)
+		}
+		if contentLength := w.Header().Get("Content-Length"); contentLength != "16" {
+			t.Errorf("Expected Content-Length '16', got '%s'", contentLength)
+		}
+	})
+
+	t.Run("Streaming handlers bypass buffering", func(t *testing.T) {
+		modifyCalled := false
+		handler := ResponseRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("first "))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("second"))
+		}), func(r *http.Request, response *BufferedResponse) {
+			modifyCalled = true
+		})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if modifyCalled {
+			t.Error("Modify should not be called for streaming responses")
+		}
+		if !w.Flushed {
+			t.Error("Expected the response to be flushed")
+		}
+		if body := w.Body.String(); body != "first second" {
+			t.Errorf("Expected 'first second', got '%s'", body)
+		}
+	})
+}
+
+func TestStatusWriterFlushWithoutFlusher(t *testing.T) {
+	sw := &statusWriter{ResponseWriter: nonFlushingWriter{httptest.NewRecorder()}}
+	// must not panic when the underlying writer cannot flush
+	sw.Flush()
+	if canFlush(nonFlushingWriter{httptest.NewRecorder()}) {
+		t.Error("Expected canFlush to be false for a writer without http.Flusher")
+	}
+	if canFlush(sw) {
+		t.Error("Expected canFlush to be false for statusWriter wrapping a writer without http.Flusher")
+	}
+	if !canFlush(&statusWriter{ResponseWriter: httptest.NewRecorder()}) {
+		t.Error("Expected canFlush to be true for statusWriter wrapping a flushing writer")
+	}
+}
+
+func TestInFlightRouter(t *testing.T) {
+	var countInHandler int
+	handler := InFlightRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		countInHandler = InFlightCount()
+	}))
+
+	before := InFlightCount()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if countInHandler != before+1 {
+		t.Errorf("Expected %d requests in flight during the request, got %d", before+1, countInHandler)
+	}
+	if InFlightCount() != before {
+		t.Errorf("Expected %d requests in flight after the request, got %d", before, InFlightCount())
+	}
+
+	t.Run("Decrements when the handler panics", func(t *testing.T) {
+		panicking := InFlightRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+		RecoveryRouter(panicking).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if InFlightCount() != before {
+			t.Errorf("Expected %d requests in flight, got %d", before, InFlightCount())
+		}
+	})
+}
+
+func TestConcurrencyLimitRouter(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		wait           bool
+		cancel         bool
+		expectedStatus int
+	}{
+		{"Rejects when full", false, false, http.StatusServiceUnavailable},
+		{"Gives up waiting when canceled", true, true, http.StatusServiceUnavailable},
+		{"Waits for a slot", true, false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ConcurrencyLimitRouter(blocking, 1, tt.wait)
+			done := make(chan struct{})
+			go func() {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+				close(done)
+			}()
+			<-entered
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			} else {
+				defer cancel()
+			}
+			if tt.expectedStatus == http.StatusOK {
+				// free the slot shortly after the second request starts waiting
+				time.AfterFunc(10*time.Millisecond, func() { release <- struct{}{} })
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				release <- struct{}{}
+			}
+			<-done
+		})
+	}
+
+	for _, max := range []int{0, -1} {
+		t.Run(fmt.Sprintf("Rejects max %d", max), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for max %d", max)
+				}
+			}()
+			ConcurrencyLimitRouter(blocking, max, true)
+		})
+	}
+}
+
+func TestRequirePreconditionRouter(t *testing.T) {
+	handler := RequirePreconditionRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected int
+	}{
+		{"Mutation without precondition", "PUT", nil, http.StatusPreconditionRequired},
+		{"Mutation with If-Match", "PUT", map[string]string{"If-Match": `"v1"`}, http.StatusNoContent},
+		{"Mutation with If-Unmodified-Since", "DELETE", map[string]string{"If-Unmodified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusNoContent},
+		{"Other methods are not checked", "GET", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/documents/1", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestRequestIDRouter(t *testing.T) {
+	var requestID string
+	handler := RequestIDRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = GetRequestID(r.Context())
+	}))
+
+	t.Run("Reuses a valid inbound request ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "upstream-123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if requestID != "upstream-123" {
+			t.Errorf("Expected 'upstream-123' in context, got '%s'", requestID)
+		}
+		if header := w.Header().Get("X-Request-ID"); header != "upstream-123" {
+			t.Errorf("Expected 'upstream-123' in response header, got '%s'", header)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		inbound string
+	}{
+		{"Generates an ID when absent", ""},
+		{"Replaces an ID with invalid characters", "abc\r\nSet-Cookie: x"},
+		{"Replaces an overly long ID", strings.Repeat("a", 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.inbound != "" {
+				req.Header.Set("X-Request-ID", tt.inbound)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if _, err := uuid.Parse(requestID); err != nil {
+				t.Errorf("Expected a generated UUID, got '%s'", requestID)
+			}
+			if w.Header().Get("X-Request-ID") != requestID {
+				t.Error("Expected the response header to match the context value")
+			}
+		})
+	}
+}
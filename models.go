@@ -1,14 +1,48 @@
 package restapi
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Response struct {
 	Timestamp int64       `json:"timestamp"`
 	Data      interface{} `json:"data"`
+	Warnings  []Warning   `json:"warnings,omitempty"`
+	// Meta holds metadata about the data, e.g. PageInfo written by WriteJSONPaginated
+	Meta interface{} `json:"meta,omitempty"`
+}
+
+var timestampFormat = ""
+
+// SetTimestampFormat sets the time layout, e.g. time.RFC3339, the default response and error
+// templates use for the timestamp field. An empty layout (default) means Unix seconds.
+func SetTimestampFormat(layout string) {
+	timestampFormat = layout
+}
+
+// MarshalJSON encodes the timestamp in the format set with SetTimestampFormat
+func (response Response) MarshalJSON() ([]byte, error) {
+	type plainResponse Response
+	if timestampFormat == "" {
+		return json.Marshal(plainResponse(response))
+	}
+	return json.Marshal(struct {
+		Timestamp string      `json:"timestamp"`
+		Data      interface{} `json:"data"`
+		Warnings  []Warning   `json:"warnings,omitempty"`
+		Meta      interface{} `json:"meta,omitempty"`
+	}{time.Unix(response.Timestamp, 0).UTC().Format(timestampFormat), response.Data, response.Warnings, response.Meta})
 }
 
 func getDefaultJSONResponse(data interface{}) interface{} {
@@ -31,33 +65,305 @@ func SetJSONResponseFormatter(f func(interface{}) interface{}) {
 	jsonResponseFormatter = f
 }
 
-func writeJSON(w http.ResponseWriter, data interface{}, usesTemplate bool) error {
+// writeJSON writes data as JSON with contentType. meta is added to the default Response template, if used.
+func writeJSON(w http.ResponseWriter, contentType string, data interface{}, usesTemplate bool, meta interface{}) error {
 	sw := &statusWriter{ResponseWriter: w}
-	sw.Header().Set("Content-Type", "application/json")
+	sw.Header().Set("Content-Type", contentType)
 	if sw.status == 0 {
-		if data == nil {
-			sw.WriteHeader(http.StatusNoContent)
+		if isNilData(data) {
+			writeEmpty(sw, responseStatus(w, http.StatusNoContent))
 			return nil
 		} else {
-			sw.WriteHeader(http.StatusOK)
+			sw.WriteHeader(responseStatus(w, http.StatusOK))
 		}
 	}
 	if usesTemplate {
 		data = jsonResponseFormatter(data)
+		if response, ok := data.(Response); ok {
+			if routeContext := routeContextFromWriter(w); routeContext != nil && warningMode == WarningsInBody {
+				response.Warnings = routeContext.Warnings()
+			}
+			response.Meta = meta
+			data = response
+		}
 	}
 	return json.NewEncoder(sw).Encode(data)
 }
 
+// responseStatus returns the status set with RouteContext.SetStatus, or defaultStatus
+func responseStatus(w http.ResponseWriter, defaultStatus int) int {
+	if routeContext := routeContextFromWriter(w); routeContext != nil && routeContext.status != 0 {
+		return routeContext.status
+	}
+	return defaultStatus
+}
+
+// writeEmpty writes a response without a body and an explicit Content-Length of 0,
+// which some clients and proxies require
+func writeEmpty(w http.ResponseWriter, statusCode int) {
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(statusCode)
+}
+
+// isNilData reports whether data is nil or a nil pointer, e.g. a *User not found in a store,
+// which is written as a 204 No Content response
+func isNilData(data interface{}) bool {
+	if data == nil {
+		return true
+	}
+	value := reflect.ValueOf(data)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
+
+// WriteNoContent writes an empty 204 No Content response
+func WriteNoContent(w http.ResponseWriter) {
+	writeEmpty(w, http.StatusNoContent)
+}
+
 // WriteJSON writes a JSON response to the ResponseWriter
 func WriteJSON(w http.ResponseWriter, data interface{}) error {
-	return writeJSON(w, data, true)
+	return writeJSON(w, "application/json", data, true, nil)
 }
 
 func WriteJSONWithoutTemplate(w http.ResponseWriter, data interface{}) error {
-	return writeJSON(w, data, false)
+	return writeJSON(w, "application/json", data, false, nil)
+}
+
+// WriteJSONWithContentType writes data as JSON with a JSON based media type such as
+// application/vnd.api+json. Like WriteJSONWithoutTemplate, data is written as is, since such
+// media types define their own document structure.
+func WriteJSONWithContentType(w http.ResponseWriter, contentType string, data interface{}) error {
+	return writeJSON(w, contentType, data, false, nil)
+}
+
+// WriteJSONOrNotFound writes data like WriteJSON, or a 404 error response when data is nil or a nil pointer
+func WriteJSONOrNotFound(w http.ResponseWriter, data interface{}) error {
+	if isNilData(data) {
+		return WriteError(w, NewHTTPError(http.StatusNotFound, ""))
+	}
+	return WriteJSON(w, data)
+}
+
+// WriteJSONFiltered writes data like WriteJSON, trimmed to the comma separated fields of the request's
+// "fields" query parameter, e.g. ?fields=id,name. Only top-level objects, or the objects of a top-level
+// array, are filtered. Unknown fields are ignored and all fields are written without the parameter.
+func WriteJSONFiltered(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	fields := r.URL.Query().Get("fields")
+	if fields == "" || isNilData(data) {
+		return WriteJSON(w, data)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var filtered interface{}
+	if err := decoder.Decode(&filtered); err != nil {
+		return err
+	}
+	selected := map[string]bool{}
+	for _, field := range strings.Split(fields, ",") {
+		selected[strings.TrimSpace(field)] = true
+	}
+	switch value := filtered.(type) {
+	case map[string]interface{}:
+		filterFields(value, selected)
+	case []interface{}:
+		for _, item := range value {
+			if object, ok := item.(map[string]interface{}); ok {
+				filterFields(object, selected)
+			}
+		}
+	}
+	return WriteJSON(w, filtered)
+}
+
+// filterFields removes the keys of object that are not selected
+func filterFields(object map[string]interface{}, selected map[string]bool) {
+	for key := range object {
+		if !selected[key] {
+			delete(object, key)
+		}
+	}
+}
+
+// WriteJSONCacheable writes a JSON response with an ETag and responds with 304 Not Modified
+// when the request's If-None-Match header matches. The ETag is computed from the data rather
+// than the formatted response, so that template fields such as the timestamp don't change it.
+func WriteJSONCacheable(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	if isNilData(data) {
+		return WriteJSON(w, data)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(encoded)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	return WriteJSON(w, data)
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag using weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// streamFlushInterval is the number of items StreamJSONArray writes between flushes
+const streamFlushInterval = 100
+
+// StreamJSONArray writes the items received from items as a JSON array without the response template,
+// flushing periodically so that large result sets don't have to be held in memory. The array is closed
+// when items is closed. If an item can't be encoded or the client goes away, the response is left
+// unterminated so that clients can tell it is incomplete, the error is returned and the remaining
+// items are drained in the background, so the producer must close items.
+func StreamJSONArray(w http.ResponseWriter, items <-chan interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(responseStatus(w, http.StatusOK))
+	if err := streamJSONArray(w, items); err != nil {
+		go func() {
+			for range items {
+			}
+		}()
+		return err
+	}
+	flushWriter(w)
+	return nil
+}
+
+func streamJSONArray(w http.ResponseWriter, items <-chan interface{}) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	count := 0
+	for item := range items {
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			encoded = append([]byte(","), encoded...)
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushInterval == 0 {
+			flushWriter(w)
+		}
+	}
+	_, err := w.Write([]byte("]"))
+	return err
 }
 
 // ReadJSON reads a JSON request from the Request and decodes it into the provided interface
 func ReadJSON(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
+
+// ReadJSONMerge decodes a partial JSON object from the request body onto existing, which must be a
+// pointer, e.g. for PATCH handlers. Fields missing from the body keep their current values, nested
+// objects are merged the same way and arrays are replaced. existing is only updated if the whole
+// body decodes, although maps and pointers it shares with its previous value may be changed.
+func ReadJSONMerge(r *http.Request, existing interface{}) error {
+	target := reflect.ValueOf(existing)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return errors.New("existing must be a non-nil pointer")
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return errors.New("request body must be a JSON object")
+	}
+	merged := reflect.New(target.Elem().Type())
+	merged.Elem().Set(target.Elem())
+	if err := json.Unmarshal(body, merged.Interface()); err != nil {
+		return err
+	}
+	target.Elem().Set(merged.Elem())
+	return nil
+}
+
+// supportedContentTypes lists the response content types Write can produce, in order of preference
+var supportedContentTypes = []string{"application/json", "application/xml", "text/xml"}
+
+// negotiateContentType picks the supported content type that best matches the Accept header.
+// It defaults to JSON when the header is empty or no supported type is acceptable.
+func negotiateContentType(accept string) string {
+	best := supportedContentTypes[0]
+	bestQuality := -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= 0 || quality <= bestQuality {
+			continue
+		}
+		for _, contentType := range supportedContentTypes {
+			if mediaTypeMatches(mediaType, contentType) {
+				best = contentType
+				bestQuality = quality
+				break
+			}
+		}
+	}
+	return best
+}
+
+// mediaTypeMatches reports whether an Accept media range such as "*/*" or "text/*" covers contentType
+func mediaTypeMatches(mediaRange, contentType string) bool {
+	if mediaRange == "*/*" || mediaRange == contentType {
+		return true
+	}
+	rangeType, rangeSubtype, _ := strings.Cut(mediaRange, "/")
+	contentTypeType, _, _ := strings.Cut(contentType, "/")
+	return rangeSubtype == "*" && rangeType == contentTypeType
+}
+
+func writeXML(w http.ResponseWriter, data interface{}, contentType string) error {
+	sw := &statusWriter{ResponseWriter: w}
+	sw.Header().Set("Content-Type", contentType)
+	if isNilData(data) {
+		writeEmpty(sw, responseStatus(w, http.StatusNoContent))
+		return nil
+	}
+	// encode before writing the status, so that an error can still be written by the caller
+	var body bytes.Buffer
+	body.WriteString(xml.Header)
+	if err := xml.NewEncoder(&body).Encode(data); err != nil {
+		return err
+	}
+	sw.WriteHeader(responseStatus(w, http.StatusOK))
+	_, err := sw.Write(body.Bytes())
+	return err
+}
+
+// Write writes data as JSON or XML depending on the request's Accept header.
+// JSON responses use the JSON response formatter, JSON is used when no acceptable type matches.
+func Write(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	contentType := negotiateContentType(r.Header.Get("Accept"))
+	if contentType == "application/json" {
+		return WriteJSON(w, data)
+	}
+	return writeXML(w, data, contentType)
+}
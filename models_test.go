@@ -0,0 +1,446 @@
+package restapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func TestWriteContentNegotiation(t *testing.T) {
+	user := testUser{ID: 1, Name: "John Doe"}
+
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "application/json"},
+		{"application/json", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/xml", "text/xml"},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", "application/xml"},
+		{"application/xml;q=0.5, application/json", "application/json"},
+		{"text/html", "application/json"},
+		{"*/*", "application/json"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		if err := Write(w, req, user); err != nil {
+			t.Fatalf("Accept '%s': unexpected error: %v", tt.accept, err)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != tt.contentType {
+			t.Errorf("Accept '%s': expected Content-Type '%s', got '%s'", tt.accept, tt.contentType, contentType)
+		}
+	}
+
+	t.Run("XML body", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		Write(w, req, user)
+
+		if !strings.Contains(w.Body.String(), "<testUser><id>1</id><name>John Doe</name></testUser>") {
+			t.Errorf("Unexpected XML body: %s", w.Body.String())
+		}
+	})
+
+	t.Run("JSON body uses the response formatter", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		w := httptest.NewRecorder()
+		Write(w, req, user)
+
+		var response struct {
+			Timestamp int64    `json:"timestamp"`
+			Data      testUser `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data != user || response.Timestamp == 0 {
+			t.Errorf("Unexpected JSON response: %+v", response)
+		}
+	})
+
+	t.Run("Encoding errors leave the response unwritten", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		if err := Write(w, req, map[string]string{"id": "1"}); err == nil {
+			t.Fatal("Expected an error for a value XML can't encode")
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected no body, got %q", w.Body.String())
+		}
+		WriteError(w, NewHTTPError(http.StatusInternalServerError, ""))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected the error status to be written, got %d", w.Code)
+		}
+	})
+
+	t.Run("Nil data returns 204", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		Write(w, req, nil)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+	})
+}
+
+func TestNoContentResponses(t *testing.T) {
+	t.Run("WriteJSON with nil data sets Content-Length 0", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if err := WriteJSON(w, nil); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+		if contentLength := w.Header().Get("Content-Length"); contentLength != "0" {
+			t.Errorf("Expected Content-Length '0', got '%s'", contentLength)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body, got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("WriteJSON with a nil pointer writes an empty 204", func(t *testing.T) {
+		var user *testUser
+		w := httptest.NewRecorder()
+		if err := WriteJSON(w, user); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body, got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("WriteNoContent writes an empty 204", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		WriteNoContent(w)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+		if contentLength := w.Header().Get("Content-Length"); contentLength != "0" {
+			t.Errorf("Expected Content-Length '0', got '%s'", contentLength)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body, got '%s'", w.Body.String())
+		}
+	})
+}
+
+func TestWriteJSONCacheable(t *testing.T) {
+	user := testUser{ID: 1, Name: "John Doe"}
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	w := httptest.NewRecorder()
+	if err := WriteJSONCacheable(w, req, user); err != nil {
+		t.Fatal(err)
+	}
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d and '%s'", w.Code, etag)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		expected    int
+	}{
+		{"Matching ETag returns 304", etag, http.StatusNotModified},
+		{"Weak matching ETag returns 304", `"other", W/` + etag, http.StatusNotModified},
+		{"Wildcard returns 304", "*", http.StatusNotModified},
+		{"Stale ETag returns 200", `"stale"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users/1", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			w := httptest.NewRecorder()
+			WriteJSONCacheable(w, req, user)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected empty body for 304, got '%s'", w.Body.String())
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("Expected ETag '%s', got '%s'", etag, w.Header().Get("ETag"))
+			}
+		})
+	}
+
+	t.Run("Changed data gets a different ETag", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		WriteJSONCacheable(w, httptest.NewRequest("GET", "/users/1", nil), testUser{ID: 1, Name: "Jane Doe"})
+		if w.Header().Get("ETag") == etag {
+			t.Error("Expected a different ETag for changed data")
+		}
+	})
+}
+
+func TestRouteContextSetStatus(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("POST", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		ctx.SetStatus(http.StatusCreated)
+		WriteJSON(w, testUser{ID: 1, Name: "John"})
+	})
+	router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		WriteJSON(w, []testUser{})
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		expected int
+	}{
+		{"Status set via the context is used", "POST", http.StatusCreated},
+		{"Default status without SetStatus", "GET", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, "/users", nil))
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Expected JSON response, got '%s'", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestWriteJSONWithContentType(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("POST", "/articles", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		ctx.SetStatus(http.StatusCreated)
+		WriteJSONWithContentType(w, "application/vnd.api+json", map[string]interface{}{"data": map[string]string{"type": "articles", "id": "1"}})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/articles", nil))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/vnd.api+json" {
+		t.Errorf("Expected Content-Type application/vnd.api+json, got '%s'", contentType)
+	}
+	expected := `{"data":{"id":"1","type":"articles"}}` + "\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected body %q without the response template, got %q", expected, w.Body.String())
+	}
+}
+
+func TestSetTimestampFormat(t *testing.T) {
+	defer SetTimestampFormat("")
+
+	tests := []struct {
+		name          string
+		layout        string
+		expected      string
+		expectedError string
+	}{
+		{"Unix seconds by default", "", `{"timestamp":1700000000,"data":"ok"}`, `{"timestamp":1700000000,"error":"Not Found"}`},
+		{"RFC3339", time.RFC3339, `{"timestamp":"2023-11-14T22:13:20Z","data":"ok"}`, `{"timestamp":"2023-11-14T22:13:20Z","error":"Not Found"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetTimestampFormat(tt.layout)
+			encoded, err := json.Marshal(Response{Timestamp: 1700000000, Data: "ok"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if string(encoded) != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, encoded)
+			}
+			encoded, err = json.Marshal(ErrorResponse{Timestamp: 1700000000, Error: "Not Found"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if string(encoded) != tt.expectedError {
+				t.Errorf("Expected %s, got %s", tt.expectedError, encoded)
+			}
+		})
+	}
+}
+
+func TestWriteJSONOrNotFound(t *testing.T) {
+	var missing *testUser
+	tests := []struct {
+		name           string
+		data           interface{}
+		expectedStatus int
+	}{
+		{"Found", &testUser{ID: 1, Name: "John Doe"}, http.StatusOK},
+		{"Nil pointer", missing, http.StatusNotFound},
+		{"Nil", nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WriteJSONOrNotFound(w, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestWriteJSONFiltered(t *testing.T) {
+	user := testUser{ID: 1, Name: "John Doe"}
+	tests := []struct {
+		name     string
+		query    string
+		data     interface{}
+		expected string
+	}{
+		{"Without fields", "", user, `{"id":1,"name":"John Doe"}`},
+		{"Selected fields", "?fields=name", user, `{"name":"John Doe"}`},
+		{"Unknown fields are ignored", "?fields=id,%20email", map[string]interface{}{"id": 1, "name": "John Doe"}, `{"id":1}`},
+		{"Objects of an array", "?fields=id", []testUser{user, {ID: 2}}, `[{"id":1},{"id":2}]`},
+		{"Other values", "?fields=id", 42, `42`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WriteJSONFiltered(w, httptest.NewRequest("GET", "/users"+tt.query, nil), tt.data); err != nil {
+				t.Fatal(err)
+			}
+			var response struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if string(response.Data) != tt.expected {
+				t.Errorf("Expected data %s, got %s", tt.expected, response.Data)
+			}
+		})
+	}
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	t.Run("Writes all items as an array", func(t *testing.T) {
+		items := make(chan interface{})
+		go func() {
+			defer close(items)
+			for i := 0; i < 250; i++ {
+				items <- testUser{ID: i}
+			}
+		}()
+		w := httptest.NewRecorder()
+		if err := StreamJSONArray(w, items); err != nil {
+			t.Fatal(err)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got '%s'", contentType)
+		}
+		var users []testUser
+		if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+			t.Fatalf("Expected a valid JSON array, got error %v", err)
+		}
+		if len(users) != 250 || users[249].ID != 249 {
+			t.Errorf("Expected 250 users in order, got %d", len(users))
+		}
+		if !w.Flushed {
+			t.Error("Expected the response to be flushed")
+		}
+	})
+
+	t.Run("Writes an empty array", func(t *testing.T) {
+		items := make(chan interface{})
+		close(items)
+		w := httptest.NewRecorder()
+		if err := StreamJSONArray(w, items); err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != "[]" {
+			t.Errorf("Expected '[]', got '%s'", w.Body.String())
+		}
+	})
+
+	t.Run("Leaves the array unterminated on an encoding error", func(t *testing.T) {
+		items := make(chan interface{})
+		go func() {
+			defer close(items)
+			items <- testUser{ID: 1}
+			items <- func() {}
+			items <- testUser{ID: 2}
+		}()
+		w := httptest.NewRecorder()
+		if err := StreamJSONArray(w, items); err == nil {
+			t.Error("Expected an encoding error")
+		}
+		if w.Body.String() != `[{"id":1,"name":""}` {
+			t.Errorf("Expected an unterminated array, got '%s'", w.Body.String())
+		}
+	})
+}
+
+func TestReadJSONMerge(t *testing.T) {
+	type address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+	type profile struct {
+		Name    string   `json:"name"`
+		Age     int      `json:"age"`
+		Tags    []string `json:"tags"`
+		Address address  `json:"address"`
+	}
+	original := profile{Name: "John", Age: 30, Tags: []string{"a", "b"}, Address: address{City: "Helsinki", Country: "FI"}}
+
+	tests := []struct {
+		name          string
+		body          string
+		expected      profile
+		expectedError bool
+	}{
+		{"Keeps omitted fields", `{"age": 31}`, profile{Name: "John", Age: 31, Tags: []string{"a", "b"}, Address: address{City: "Helsinki", Country: "FI"}}, false},
+		{"Sets zero values that are given", `{"name": "", "age": 0}`, profile{Tags: []string{"a", "b"}, Address: address{City: "Helsinki", Country: "FI"}}, false},
+		{"Merges nested objects", `{"address": {"city": "Espoo"}}`, profile{Name: "John", Age: 30, Tags: []string{"a", "b"}, Address: address{City: "Espoo", Country: "FI"}}, false},
+		{"Replaces arrays", `{"tags": ["c"]}`, profile{Name: "John", Age: 30, Tags: []string{"c"}, Address: address{City: "Helsinki", Country: "FI"}}, false},
+		{"Leaves the value unchanged on a type error", `{"name": "Jane", "age": "old"}`, original, true},
+		{"Rejects non-objects", `[1, 2]`, original, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := original
+			existing.Tags = append([]string(nil), original.Tags...)
+			req := httptest.NewRequest("PATCH", "/profile", strings.NewReader(tt.body))
+			err := ReadJSONMerge(req, &existing)
+			if (err != nil) != tt.expectedError {
+				t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if !reflect.DeepEqual(existing, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, existing)
+			}
+		})
+	}
+}
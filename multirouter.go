@@ -1,36 +1,144 @@
 package restapi
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 type MultiRouter struct {
-	BasePath   string
-	Routers    []*Router
+	BasePath string
+	Routers  []*Router
+	// CORSConfig applies to all routers and overrides their own CORSConfig
 	CORSConfig *CORSConfig
+	// DefaultCORSConfig applies to routers without a CORSConfig when CORSConfig is nil.
+	// When it is nil too, the default policy allowing any origin without credentials is used.
+	DefaultCORSConfig *CORSConfig
+	// Metadata is made available to handlers served by this MultiRouter via RouteContext.RouterMetadata
+	Metadata map[string]interface{}
+	// PreflightAnyPath answers CORS preflight requests to any path under BasePath, even if no
+	// route matches, e.g. for paths that only exist dynamically. Other requests to unmatched
+	// paths still get 404.
+	PreflightAnyPath bool
+	// CaseInsensitive matches BasePath regardless of case, e.g. "/API" for "/api". Set
+	// CaseInsensitive on the routers too to match their routes regardless of case.
+	CaseInsensitive bool
+
+	middlewares []func(http.Handler) http.Handler
+	// handler is the MultiRouter wrapped in middlewares, nil if there are none
+	handler http.Handler
+}
+
+// Use adds middlewares shared by all routers of the MultiRouter. They wrap every request under
+// BasePath, before the middlewares of the serving router. The first middleware is the outermost one.
+func (mr *MultiRouter) Use(middlewares ...func(http.Handler) http.Handler) {
+	mr.middlewares = append(mr.middlewares, middlewares...)
+	mr.handler = chainMiddlewares(http.HandlerFunc(mr.serveHTTP), mr.middlewares)
 }
 
+var contextKeyRouterMetadata = contextKey("routerMetadata")
+
+// contextKeyPathPrefix holds the base path of the MultiRouter serving the request
+var contextKeyPathPrefix = contextKey("pathPrefix")
+
 // NewMultiRouter is a constructor function for MultiRouter
 func NewMultiRouter(basePath string, routers []*Router) (*MultiRouter, error) {
 	if basePath == "" || basePath == "/" {
 		return nil, errors.New("basePath cannot be empty or '/' for MultiRouter. If you want to use '/' as basePath, use a single Router instead")
 	}
 
-	// reconfigure router routes
-	for _, router := range routers {
-		for i, route := range router.Routes {
-			router.Routes[i].RelativePath = basePath + route.RelativePath
-		}
+	if err := checkRouteCollisions(routers); err != nil {
+		return nil, err
 	}
 
+	// routes are matched relative to basePath, so the routers are not modified and can be reused
 	return &MultiRouter{
 		BasePath: basePath,
 		Routers:  routers,
 	}, nil
 }
 
+// checkRouteCollisions returns an error if two routers register the same method and path in a
+// common environment, in which case only the first router would ever serve the route. Like
+// Router.Validate, paths differing only in case or by a trailing slash collide when either router
+// matches them regardless of it.
+func checkRouteCollisions(routers []*Router) error {
+	for i, router := range routers {
+		for j := range router.Routes {
+			route := &router.Routes[j]
+			for _, other := range routers[:i] {
+				caseInsensitive := router.CaseInsensitive || other.CaseInsensitive
+				ignoreTrailingSlash := router.IgnoreTrailingSlash || other.IgnoreTrailingSlash
+				for k := range other.Routes {
+					if routesConflict(route, &other.Routes[k], caseInsensitive, ignoreTrailingSlash) {
+						return fmt.Errorf("route %s %s is registered by multiple routers", route.Method, route.RelativePath)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeRouteTemplate strips parameter names from a route template so that templates
+// matching the same paths compare equal, e.g. "/users/:id" and "/users/:userId". Static
+// segments are lowercased when the template is matched case-insensitively.
+func normalizeRouteTemplate(template string, caseInsensitive bool) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if wildcardName(segment) != "" {
+			segments[i] = "*"
+		} else if name, pattern := parseParamSegment(segment); name != "" {
+			segments[i] = ":(" + pattern + ")"
+		} else if caseInsensitive {
+			segments[i] = strings.ToLower(segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// allowedMethods returns the sorted union of the methods registered for path across all routers, including OPTIONS
+func (mr *MultiRouter) allowedMethods(path string) []string {
+	seen := map[string]bool{"OPTIONS": true}
+	methods := []string{"OPTIONS"}
+	for _, router := range mr.Routers {
+		for _, method := range router.allowedMethods(path) {
+			if !seen[method] {
+				seen[method] = true
+				methods = append(methods, method)
+			}
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// corsConfigFor returns the CORS configuration for requests served by router, which may be nil:
+// the CORSConfig of the MultiRouter, else the CORSConfig of router, else DefaultCORSConfig.
+// nil means the package default policy, see applyDefaultCORS.
+func (mr *MultiRouter) corsConfigFor(router *Router) *CORSConfig {
+	if mr.CORSConfig != nil {
+		return mr.CORSConfig
+	}
+	if router != nil && router.CORSConfig != nil {
+		return router.CORSConfig
+	}
+	return mr.DefaultCORSConfig
+}
+
+// advertiseAllowedMethods replaces the default Access-Control-Allow-Methods of a CORS response
+// with the methods registered for the requested path, so that e.g. a PATCH route passes the
+// preflight. Methods listed in the AllowedMethods of config are kept.
+func advertiseAllowedMethods(w http.ResponseWriter, config *CORSConfig, allowedMethods string) {
+	if w.Header().Get("Access-Control-Allow-Methods") == "" || (config != nil && len(config.AllowedMethods) > 0) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+}
+
 // NewMultiRouterWithCORS creates a MultiRouter with CORS configuration
 // This will override all individual router CORS settings
 func NewMultiRouterWithCORS(basePath string, routers []*Router, corsConfig *CORSConfig) (*MultiRouter, error) {
@@ -48,20 +156,67 @@ func NewMultiRouterWithCORS(basePath string, routers []*Router, corsConfig *CORS
 	return mr, nil
 }
 
+// ListRoutes returns the routes of all routers, prefixed with the base path
 func (mr *MultiRouter) ListRoutes() []string {
+	basePath := strings.TrimSuffix(mr.BasePath, "/")
 	var routes []string
 	for _, router := range mr.Routers {
 		for _, route := range router.Routes {
-			routes = append(routes, route.Method+" "+route.RelativePath)
+			routes = append(routes, route.Method+" "+basePath+route.RelativePath)
 		}
 	}
 	return routes
 }
 
+// ListRouteInfo returns the routes of all routers, with paths prefixed with the base path
+func (mr *MultiRouter) ListRouteInfo() []RouteInfo {
+	basePath := strings.TrimSuffix(mr.BasePath, "/")
+	var routes []RouteInfo
+	for _, router := range mr.Routers {
+		for _, route := range router.ListRouteInfo() {
+			route.Path = basePath + route.Path
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// requestLimits returns the largest MaxPathLength and MaxHeaderSize of the routers, or zero when a
+// router has no limit, so that requests none of the routers would accept are rejected before matching.
+// The serving router applies its own limits afterwards.
+func (mr *MultiRouter) requestLimits() (maxPathLength, maxHeaderSize int) {
+	for i, router := range mr.Routers {
+		if i == 0 || (maxPathLength > 0 && (router.MaxPathLength == 0 || router.MaxPathLength > maxPathLength)) {
+			maxPathLength = router.MaxPathLength
+		}
+		if i == 0 || (maxHeaderSize > 0 && (router.MaxHeaderSize == 0 || router.MaxHeaderSize > maxHeaderSize)) {
+			maxHeaderSize = router.MaxHeaderSize
+		}
+	}
+	return maxPathLength, maxHeaderSize
+}
+
 func (mr *MultiRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	// Check if the request path starts with the base path
+	if mr.handler != nil {
+		mr.handler.ServeHTTP(w, req)
+		return
+	}
+	mr.serveHTTP(w, req)
+}
+
+func (mr *MultiRouter) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if maxPathLength, maxHeaderSize := mr.requestLimits(); !checkRequestLimits(w, req, maxPathLength, maxHeaderSize) {
+		return
+	}
+
+	// Check if the request path starts with the base path on a segment boundary,
+	// so that /api/v1extra is not served by a MultiRouter at /api/v1
 	basePath := strings.TrimSuffix(mr.BasePath, "/")
-	if !strings.HasPrefix(req.URL.Path, basePath) {
+	path, ok := strings.CutPrefix(req.URL.Path, basePath)
+	if mr.CaseInsensitive {
+		path, ok = cutPrefixFold(req.URL.Path, basePath)
+	}
+	if !ok || (path != "" && !strings.HasPrefix(path, "/")) {
 		http.NotFound(w, req)
 		return
 	}
@@ -70,85 +225,81 @@ func (mr *MultiRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var matchingRouter *Router
 	var routeFound bool
 
+	// The router with the most specific matching route serves the request
+	var matchedRoute *Route
+	method := req.Method
+	// For OPTIONS requests, check if this path would match any method
+	if method == "OPTIONS" {
+		method = ""
+	}
 	for _, router := range mr.Routers {
-		for _, route := range router.Routes {
-			routeSegments := strings.Split(route.RelativePath, "/")
-			pathSegments := strings.Split(req.URL.Path, "/")
-			if len(routeSegments) == len(pathSegments) {
-				match := true
-				for i, routeSegment := range routeSegments {
-					if strings.HasPrefix(routeSegment, ":") {
-						// Parameter match - always matches
-						continue
-					} else if routeSegment != pathSegments[i] {
-						match = false
-						break
-					}
-				}
-				if match {
-					matchingRouter = router
-					// For OPTIONS requests, check if this path would match any method
-					if req.Method == "OPTIONS" {
-						routeFound = true
-						break
-					}
-					// For non-OPTIONS requests, also check method
-					if req.Method == route.Method {
-						routeFound = true
-						break
-					}
-				}
-			}
+		routeMethod := method
+		if router.MethodOverride && method != "" {
+			routeMethod = overrideMethod(req).Method
 		}
-		if routeFound {
-			break
+		if route, _ := router.match(routeMethod, path); route != nil && (matchedRoute == nil || moreSpecific(route, matchedRoute)) {
+			matchedRoute = route
+			matchingRouter = router
+			routeFound = true
 		}
 	}
-
 	if !routeFound {
+		for _, router := range mr.Routers {
+			// Let the router redirect to the canonical trailing-slash form
+			if _, ok := router.slashRedirectPath(method, path); ok {
+				matchingRouter = router
+				routeFound = true
+				break
+			}
+		}
+	}
+
+	// a preflight to any path under the base path is answered when PreflightAnyPath is set
+	preflightAnyPath := mr.PreflightAnyPath && isPreflightRequest(req)
+	if !routeFound && !preflightAnyPath {
 		http.NotFound(w, req)
 		return
 	}
 
-	// Handle CORS - either at MultiRouter level or per-router level
-	if mr.CORSConfig != nil {
-		// MultiRouter-level CORS overrides individual router CORS
-		mr.CORSConfig.HandleCORS(w, req)
-		if req.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-	} else if matchingRouter != nil {
-		// Per-router CORS handling - respect global corsAlwaysOn setting
-		if matchingRouter.CORSConfig == nil {
-			// Create a temporary default config that respects corsAlwaysOn
-			requestOrigin := req.Header.Get("Origin")
-			originHeaderMissing := requestOrigin == ""
-
-			// Only set default CORS if:
-			// 1. Origin header is present, OR
-			// 2. corsAlwaysOn is enabled
-			if !originHeaderMissing || GetCORSAlwaysOn() {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-				w.Header().Set("Access-Control-Allow-Credentials", "false")
-			}
-		} else {
-			matchingRouter.CORSConfig.HandleCORS(w, req)
-		}
+	var allowedMethods string
+	if req.Method == "OPTIONS" {
+		allowedMethods = strings.Join(mr.allowedMethods(path), ", ")
+		w.Header().Set("Allow", allowedMethods)
+	}
 
-		if req.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	// CORS is handled here for the serving router, which doesn't apply its own policy
+	corsConfig := mr.corsConfigFor(matchingRouter)
+	if corsConfig == nil {
+		applyDefaultCORS(w, req)
+	} else {
+		corsConfig.HandleCORS(w, req)
+	}
+	if req.Method == "OPTIONS" {
+		if routeFound {
+			advertiseAllowedMethods(w, corsConfig, allowedMethods)
 		}
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
 	// Forward the request to the matching router
 	if matchingRouter != nil {
+		ctx := context.WithValue(req.Context(), contextKeyPathPrefix, basePath)
+		if mr.Metadata != nil {
+			ctx = context.WithValue(ctx, contextKeyRouterMetadata, mr.Metadata)
+		}
+		req = req.WithContext(ctx)
 		matchingRouter.ServeHTTP(w, req)
 		return
 	}
 
 	http.NotFound(w, req)
 }
+
+// cutPrefixFold is strings.CutPrefix ignoring case
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -3,6 +3,8 @@ package restapi
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -270,3 +272,533 @@ func TestMultiRouterCORS(t *testing.T) {
 		}
 	})
 }
+
+func TestMultiRouterCORSInheritance(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {}
+	withCORS := &Router{BasePath: "/private", CORSConfig: &CORSConfig{AllowedOrigins: []string{"https://internal.example.com"}}}
+	withCORS.HandleFunc("GET", "/data", handler)
+	withoutCORS := &Router{BasePath: "/public"}
+	withoutCORS.HandleFunc("GET", "/data", handler)
+
+	defaultConfig := &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}
+	multiConfig := &CORSConfig{AllowedOrigins: []string{"https://multi.example.com"}}
+
+	tests := []struct {
+		name           string
+		corsConfig     *CORSConfig
+		defaultConfig  *CORSConfig
+		path           string
+		origin         string
+		expectedOrigin string
+	}{
+		{"Package default without any config", nil, nil, "/api/public/data", "https://any.example.com", "*"},
+		{"Router config", nil, defaultConfig, "/api/private/data", "https://internal.example.com", "https://internal.example.com"},
+		{"Default config for routers without config", nil, defaultConfig, "/api/public/data", "https://app.example.com", "https://app.example.com"},
+		{"Default config rejects other origins", nil, defaultConfig, "/api/public/data", "https://any.example.com", ""},
+		{"MultiRouter config overrides router config", multiConfig, defaultConfig, "/api/private/data", "https://internal.example.com", ""},
+		{"MultiRouter config rejects other origins", multiConfig, nil, "/api/public/data", "https://any.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiRouter, err := NewMultiRouter("/api", []*Router{withCORS, withoutCORS})
+			if err != nil {
+				t.Fatal(err)
+			}
+			multiRouter.CORSConfig = tt.corsConfig
+			multiRouter.DefaultCORSConfig = tt.defaultConfig
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			multiRouter.ServeHTTP(w, req)
+			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin '%s', got '%s'", tt.expectedOrigin, origin)
+			}
+		})
+	}
+}
+
+func TestMultiRouterMetadata(t *testing.T) {
+	var tenant interface{}
+	router := &Router{BasePath: "/orders"}
+	router.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		tenant = ctx.RouterMetadata()["tenant"]
+		w.WriteHeader(http.StatusOK)
+	})
+
+	multiRouter, err := NewMultiRouter("/tenant-a", []*Router{router})
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiRouter.Metadata = map[string]interface{}{"tenant": "tenant-a"}
+
+	req := httptest.NewRequest("GET", "/tenant-a/orders", nil)
+	w := httptest.NewRecorder()
+	multiRouter.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if tenant != "tenant-a" {
+		t.Errorf("Expected handler to read tenant 'tenant-a' from metadata, got %v", tenant)
+	}
+
+	t.Run("Router served directly has no metadata", func(t *testing.T) {
+		direct := &Router{}
+		var metadata map[string]interface{}
+		handlerCalled := false
+		direct.HandleFunc("GET", "/ping", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			handlerCalled = true
+			metadata = ctx.RouterMetadata()
+		})
+		direct.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ping", nil))
+		if !handlerCalled {
+			t.Fatal("Expected handler to be called")
+		}
+		if metadata != nil {
+			t.Errorf("Expected nil metadata, got %v", metadata)
+		}
+	})
+}
+
+func TestMultiRouterRouteCollisions(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {}
+
+	t.Run("Same route in two routers is an error", func(t *testing.T) {
+		first := &Router{BasePath: "/users"}
+		first.HandleFunc("GET", "/:id", handler)
+		second := &Router{BasePath: "/users"}
+		second.HandleFunc("GET", "/:userId", handler)
+
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err == nil {
+			t.Error("Expected error for colliding routes")
+		}
+		if first.Routes[0].RelativePath != "/users/:id" {
+			t.Errorf("Routes should not be modified when construction fails, got '%s'", first.Routes[0].RelativePath)
+		}
+	})
+
+	t.Run("Paths differing in case collide in a case-insensitive router", func(t *testing.T) {
+		first := &Router{}
+		first.HandleFunc("GET", "/Users", handler)
+		second := &Router{CaseInsensitive: true}
+		second.HandleFunc("GET", "/users", handler)
+
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err == nil {
+			t.Error("Expected error for routes differing only in case")
+		}
+		second.CaseInsensitive = false
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err != nil {
+			t.Errorf("Unexpected error for case-sensitive routers: %v", err)
+		}
+	})
+
+	t.Run("Same path in disjoint environments is allowed", func(t *testing.T) {
+		first := &Router{}
+		first.HandleFuncForEnvironments("POST", "/seed", []string{"staging"}, handler)
+		second := &Router{}
+		second.HandleFuncForEnvironments("POST", "/seed", []string{"development"}, handler)
+
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		second.HandleFuncForEnvironments("POST", "/seed", []string{"staging"}, handler)
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err == nil {
+			t.Error("Expected error for routes sharing an environment")
+		}
+	})
+
+	t.Run("Trailing slash collides with IgnoreTrailingSlash", func(t *testing.T) {
+		first := &Router{}
+		first.HandleFunc("GET", "/users", handler)
+		second := &Router{IgnoreTrailingSlash: true}
+		second.HandleFunc("GET", "/users/", handler)
+
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err == nil {
+			t.Error("Expected error for routes differing only by a trailing slash")
+		}
+	})
+
+	t.Run("Same constraint with different parameter names collides", func(t *testing.T) {
+		first := &Router{}
+		first.HandleFunc("GET", `/users/:id(\d+)`, handler)
+		second := &Router{}
+		second.HandleFunc("GET", `/users/:userId(\d+)`, handler)
+
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err == nil {
+			t.Error("Expected error for routes with the same constraint")
+		}
+	})
+
+	t.Run("Same path with different methods is allowed", func(t *testing.T) {
+		first := &Router{BasePath: "/users"}
+		first.HandleFunc("GET", "/:id", handler)
+		second := &Router{BasePath: "/users"}
+		second.HandleFunc("DELETE", "/:id", handler)
+
+		if _, err := NewMultiRouter("/api", []*Router{first, second}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestMultiRouterReusesRouters(t *testing.T) {
+	users := &Router{BasePath: "/users"}
+	users.HandleFunc("GET", "/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		id, _ := ctx.Params.Get("id")
+		WriteJSONWithoutTemplate(w, map[string]string{"id": id})
+	})
+
+	v1, err := NewMultiRouter("/api/v1", []*Router{users})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := NewMultiRouter("/api/v2", []*Router{users})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if users.Routes[0].RelativePath != "/users/:id" {
+		t.Errorf("Expected router routes to be unmodified, got '%s'", users.Routes[0].RelativePath)
+	}
+
+	tests := []struct {
+		name   string
+		router http.Handler
+		path   string
+		status int
+	}{
+		{"First MultiRouter", v1, "/api/v1/users/42", http.StatusOK},
+		{"Second MultiRouter", v2, "/api/v2/users/42", http.StatusOK},
+		{"Other base path is not matched", v1, "/api/v2/users/42", http.StatusNotFound},
+		{"Router directly", users, "/users/42", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			test.router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+
+	t.Run("ListRoutes includes the base path", func(t *testing.T) {
+		routes := v2.ListRoutes()
+		if len(routes) != 1 || routes[0] != "GET /api/v2/users/:id" {
+			t.Errorf("Expected [GET /api/v2/users/:id], got %v", routes)
+		}
+	})
+}
+
+func TestMultiRouterBasePathBoundary(t *testing.T) {
+	catchAll := &Router{}
+	catchAll.HandleFunc("GET", "extra/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	catchAll.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	multiRouter, err := NewMultiRouter("/api/v1", []*Router{catchAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/v1/users", http.StatusOK},
+		{"/api/v1extra/users", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			multiRouter.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestMultiRouterCaseInsensitive(t *testing.T) {
+	users := &Router{BasePath: "/users", CaseInsensitive: true}
+	users.HandleFunc("GET", "/:name", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		name, _ := ctx.Params.Get("name")
+		w.Write([]byte(name))
+	})
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		path            string
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{"Base path is case-sensitive by default", false, "/API/users/John", http.StatusNotFound, ""},
+		{"Routes follow the router", false, "/api/USERS/John", http.StatusOK, "John"},
+		{"Matches a mixed-case base path", true, "/API/Users/John", http.StatusOK, "John"},
+		{"Matches a lower-case base path", true, "/api/users/john", http.StatusOK, "john"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiRouter, err := NewMultiRouter("/api", []*Router{users})
+			if err != nil {
+				t.Fatal(err)
+			}
+			multiRouter.CaseInsensitive = tt.caseInsensitive
+			w := httptest.NewRecorder()
+			multiRouter.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMultiRouterRequestLimits(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {}
+	limited := &Router{BasePath: "/items", MaxPathLength: 20, MaxHeaderSize: 64}
+	limited.HandleFunc("GET", "/:id", handler)
+	unlimited := &Router{BasePath: "/files"}
+	unlimited.HandleFunc("GET", "/*path", handler)
+
+	tests := []struct {
+		name           string
+		routers        []*Router
+		method         string
+		path           string
+		headerValue    string
+		expectedStatus int
+	}{
+		{"Within limits", []*Router{limited}, "GET", "/api/items/1", "short", http.StatusOK},
+		{"Path too long", []*Router{limited}, "GET", "/api/items/" + strings.Repeat("1", 10000), "short", http.StatusRequestURITooLong},
+		{"Path too long without a matching route", []*Router{limited}, "GET", "/api/" + strings.Repeat("1", 10000), "short", http.StatusRequestURITooLong},
+		{"Path too long for a preflight", []*Router{limited}, "OPTIONS", "/api/" + strings.Repeat("1", 10000), "short", http.StatusRequestURITooLong},
+		{"Headers too large", []*Router{limited}, "GET", "/api/items/1", strings.Repeat("x", 64), http.StatusRequestHeaderFieldsTooLarge},
+		{"Serving router applies its limits", []*Router{limited, unlimited}, "GET", "/api/items/" + strings.Repeat("1", 100), "short", http.StatusRequestURITooLong},
+		{"Router without limits serves long paths", []*Router{limited, unlimited}, "GET", "/api/files/" + strings.Repeat("1", 100), "short", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiRouter, err := NewMultiRouter("/api", tt.routers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Custom", tt.headerValue)
+			w := httptest.NewRecorder()
+			multiRouter.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestMultiRouterOptionsAllowedMethods(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {}
+	reader := &Router{BasePath: "/users"}
+	reader.HandleFunc("GET", "/:id", handler)
+	writer := &Router{BasePath: "/users"}
+	writer.HandleFunc("PATCH", "/:id", handler)
+	writer.HandleFunc("DELETE", "/:id", handler)
+	writer.HandleFunc("POST", "/", handler)
+
+	multiRouter, err := NewMultiRouter("/api", []*Router{reader, writer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("OPTIONS", "/api/users/42", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	w := httptest.NewRecorder()
+	multiRouter.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, OPTIONS, PATCH" {
+		t.Errorf("Expected Allow 'DELETE, GET, OPTIONS, PATCH', got '%s'", allow)
+	}
+	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "DELETE, GET, OPTIONS, PATCH" {
+		t.Errorf("Expected Access-Control-Allow-Methods 'DELETE, GET, OPTIONS, PATCH', got '%s'", methods)
+	}
+
+	// configured methods are kept
+	multiRouter.CORSConfig = &CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "PATCH"}}
+	w = httptest.NewRecorder()
+	multiRouter.ServeHTTP(w, req)
+	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET,PATCH" {
+		t.Errorf("Expected Access-Control-Allow-Methods 'GET,PATCH', got '%s'", methods)
+	}
+}
+
+func TestMultiRouterPreflightAnyPath(t *testing.T) {
+	users := &Router{BasePath: "/users"}
+	users.HandleFunc("GET", "/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+
+	preflight := func(path string) *http.Request {
+		req := httptest.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		return req
+	}
+
+	tests := []struct {
+		name             string
+		preflightAnyPath bool
+		req              *http.Request
+		expected         int
+	}{
+		{"Preflight to an unregistered parameterized path succeeds", true, preflight("/api/users/42/avatar"), http.StatusOK},
+		{"Preflight to an unregistered path is 404 without the flag", false, preflight("/api/users/42/avatar"), http.StatusNotFound},
+		{"Preflight outside the base path is 404", true, preflight("/other/users/42"), http.StatusNotFound},
+		{"Plain OPTIONS to an unregistered path is 404", true, httptest.NewRequest("OPTIONS", "/api/users/42/avatar", nil), http.StatusNotFound},
+		{"Other methods to an unregistered path are 404", true, httptest.NewRequest("PUT", "/api/users/42/avatar", nil), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiRouter, err := NewMultiRouterWithCORS("/api", []*Router{users}, &CORSConfig{
+				AllowedOrigins: []string{"https://app.example.com"},
+				AllowedMethods: []string{"GET", "PUT"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			multiRouter.PreflightAnyPath = tt.preflightAnyPath
+			w := httptest.NewRecorder()
+			multiRouter.ServeHTTP(w, tt.req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected == http.StatusOK && w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+				t.Errorf("Expected CORS headers, got %v", w.Header())
+			}
+		})
+	}
+
+	t.Run("Default CORS is applied without a CORS config", func(t *testing.T) {
+		multiRouter, err := NewMultiRouter("/api", []*Router{users})
+		if err != nil {
+			t.Fatal(err)
+		}
+		multiRouter.PreflightAnyPath = true
+		w := httptest.NewRecorder()
+		multiRouter.ServeHTTP(w, preflight("/api/files/1"))
+		if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("Expected 200 with default CORS headers, got %d %v", w.Code, w.Header())
+		}
+	})
+}
+
+func TestMultiRouterStaticSegmentPrecedence(t *testing.T) {
+	var served string
+	users := &Router{BasePath: "/users"}
+	users.HandleFunc("GET", "/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		served = "user"
+	})
+	account := &Router{BasePath: "/users"}
+	account.HandleFunc("GET", "/me", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		served = "me"
+	})
+	multiRouter, err := NewMultiRouter("/api", []*Router{users, account})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	multiRouter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users/me", nil))
+	if served != "me" {
+		t.Errorf("Expected the static route of the second router to win, got '%s'", served)
+	}
+}
+
+func TestMultiRouterMethodOverride(t *testing.T) {
+	items := &Router{BasePath: "/items", MethodOverride: true}
+	items.HandleFunc("DELETE", "/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	multiRouter, err := NewMultiRouter("/api", []*Router{items})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/items/1", nil)
+	req.Header.Set("X-HTTP-Method-Override", "DELETE")
+	w := httptest.NewRecorder()
+	multiRouter.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+}
+
+func TestListRouteInfo(t *testing.T) {
+	users := &Router{BasePath: "/users"}
+	users.HandleFunc("GET", "/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	users.HandleProtectedFunc("DELETE", "/:id", []Permission{2}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	users.HandleFuncWithMeta("PUT", "/:id", RouteMeta{Summary: "Replace a user", Tags: []string{"users"}}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	multiRouter, err := NewMultiRouter("/api/v1", []*Router{users})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RouteInfo{
+		{Method: "GET", Path: "/users/:id"},
+		{Method: "DELETE", Path: "/users/:id", Protected: true, RequiredPermissions: []Permission{2}},
+		{Method: "PUT", Path: "/users/:id", Meta: RouteMeta{Summary: "Replace a user", Tags: []string{"users"}}},
+	}
+	if routes := users.ListRouteInfo(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, routes)
+	}
+
+	expected[0].Path = "/api/v1/users/:id"
+	expected[1].Path = "/api/v1/users/:id"
+	expected[2].Path = "/api/v1/users/:id"
+	if routes := multiRouter.ListRouteInfo(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, routes)
+	}
+}
+
+func TestMultiRouterUse(t *testing.T) {
+	var calls []string
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	users := &Router{BasePath: "/users"}
+	users.HandleFunc("GET", "/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		calls = append(calls, "handler")
+	})
+	users.Use(tag("users"))
+	items := &Router{BasePath: "/items"}
+	items.Use(tag("items"))
+	multiRouter, err := NewMultiRouter("/api", []*Router{users, items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiRouter.Use(tag("first"), tag("second"))
+
+	multiRouter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users/1", nil))
+	expected := []string{"first", "second", "users", "handler"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+
+	calls = nil
+	w := httptest.NewRecorder()
+	multiRouter.ServeHTTP(w, httptest.NewRequest("GET", "/api/unknown", nil))
+	expected = []string{"first", "second"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package restapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// GenerateOpenAPI returns a minimal OpenAPI 3.0 JSON document describing the routes of mr that are
+// enabled in the current environment: paths, methods, path parameters and the RouteMeta.
+// Protected routes require the "authorization" security scheme, declared as a bearer token, and
+// list their required permissions in "x-required-permissions". Routes registered with
+// HandleFuncTyped also describe their request body and their response in the default response template.
+func GenerateOpenAPI(mr *MultiRouter) ([]byte, error) {
+	basePath := strings.TrimSuffix(mr.BasePath, "/")
+	paths := map[string]map[string]interface{}{}
+	hasProtectedRoutes := false
+
+	for _, router := range mr.Routers {
+		for _, route := range router.Routes {
+			if !route.enabledInCurrentEnvironment() {
+				continue
+			}
+			path, parameters := openAPIPath(basePath + route.RelativePath)
+			responses := map[string]interface{}{"200": map[string]interface{}{"description": "OK"}}
+			operation := map[string]interface{}{"responses": responses}
+			if len(parameters) > 0 {
+				operation["parameters"] = parameters
+			}
+			if route.Meta.Summary != "" {
+				operation["summary"] = route.Meta.Summary
+			}
+			if len(route.Meta.Tags) > 0 {
+				operation["tags"] = route.Meta.Tags
+			}
+			if route.Meta.Deprecated {
+				operation["deprecated"] = true
+			}
+			if route.RequestType != nil {
+				operation["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": jsonSchema(route.RequestType, map[reflect.Type]bool{})}},
+				}
+			}
+			if route.ResponseType != nil {
+				envelope := map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"timestamp": timestampSchema(),
+						"data":      jsonSchema(route.ResponseType, map[reflect.Type]bool{}),
+					},
+				}
+				responses["200"] = map[string]interface{}{
+					"description": "OK",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": envelope}},
+				}
+			}
+			if route.Protected {
+				hasProtectedRoutes = true
+				operation["security"] = []map[string][]string{{"authorization": {}}}
+				responses["401"] = map[string]interface{}{"description": "Unauthorized"}
+				responses["403"] = map[string]interface{}{"description": "Forbidden"}
+				if len(route.RequiredPermissions) > 0 {
+					operation["x-required-permissions"] = route.RequiredPermissions
+				}
+			}
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+			paths[path][strings.ToLower(route.Method)] = operation
+		}
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": basePath, "version": "1.0.0"},
+		"paths":   paths,
+	}
+	if hasProtectedRoutes {
+		document["components"] = map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"authorization": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		}
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// timestampSchema describes the timestamp field of the default response template in the format set
+// with SetTimestampFormat: Unix seconds by default, else a string
+func timestampSchema() map[string]interface{} {
+	switch timestampFormat {
+	case "":
+		return map[string]interface{}{"type": "integer"}
+	case time.RFC3339, time.RFC3339Nano:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// openAPIPath converts a route template such as "/users/:id(\d+)" to an OpenAPI path such as
+// "/users/{id}" and returns the path parameters
+func openAPIPath(template string) (string, []map[string]interface{}) {
+	var parameters []map[string]interface{}
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		name, pattern := parseParamSegment(segment)
+		if wildcard := wildcardName(segment); wildcard != "" {
+			name = wildcard
+		}
+		if name == "" {
+			continue
+		}
+		schema := map[string]interface{}{"type": "string"}
+		if pattern != "" {
+			schema["pattern"] = "^(?:" + pattern + ")$"
+		}
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": schema})
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns a schema for the JSON encoding of values of type t. seen guards against
+// recursive types, which are described as any value.
+func jsonSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// byte slices are encoded as base64 strings
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		properties := map[string]interface{}{}
+		addStructProperties(t, properties, seen)
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
+
+// addStructProperties adds the JSON properties of the fields of struct type t to properties.
+// Fields of embedded structs without a JSON name are promoted like encoding/json does.
+func addStructProperties(t reflect.Type, properties map[string]interface{}, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			addStructProperties(fieldType, properties, seen)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = jsonSchema(field.Type, seen)
+	}
+}
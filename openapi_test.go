@@ -0,0 +1,167 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type openAPITestUser struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	Password string    `json:"-"`
+	Tags     []string  `json:"tags"`
+	Created  time.Time `json:"created"`
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	users := &Router{BasePath: "/users"}
+	users.HandleFunc("GET", `/:id(\d+)`, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	users.HandleFuncWithMeta("PUT", "/:id", RouteMeta{Summary: "Replace a user", Tags: []string{"users"}, Deprecated: true}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	users.HandleProtectedFunc("DELETE", "/:id", []Permission{2}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	HandleFuncTyped(users, "POST", "/", func(ctx context.Context, in openAPITestUser, routeContext *RouteContext) (openAPITestUser, error) {
+		return in, nil
+	})
+	multiRouter, err := NewMultiRouter("/api/v1", []*Router{users})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := GenerateOpenAPI(multiRouter)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name   string            `json:"name"`
+				In     string            `json:"in"`
+				Schema map[string]string `json:"schema"`
+			} `json:"parameters"`
+			Summary             string                `json:"summary"`
+			Tags                []string              `json:"tags"`
+			Deprecated          bool                  `json:"deprecated"`
+			Security            []map[string][]string `json:"security"`
+			RequiredPermissions []Permission          `json:"x-required-permissions"`
+			RequestBody         json.RawMessage       `json:"requestBody"`
+			Responses           map[string]json.RawMessage
+		} `json:"paths"`
+		Components struct {
+			SecuritySchemes map[string]map[string]string `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		t.Fatalf("Expected valid JSON, got error %v", err)
+	}
+
+	if document.OpenAPI != "3.0.3" {
+		t.Errorf("Expected openapi 3.0.3, got %q", document.OpenAPI)
+	}
+	get, ok := document.Paths["/api/v1/users/{id}"]["get"]
+	if !ok {
+		t.Fatalf("Expected GET /api/v1/users/{id}, got paths %v", document.Paths)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" || get.Parameters[0].Schema["pattern"] != `^(?:\d+)$` {
+		t.Errorf("Expected constrained path parameter id, got %+v", get.Parameters)
+	}
+	if get.Security != nil {
+		t.Errorf("Expected no security on a public route, got %v", get.Security)
+	}
+
+	put := document.Paths["/api/v1/users/{id}"]["put"]
+	if put.Summary != "Replace a user" || !reflect.DeepEqual(put.Tags, []string{"users"}) || !put.Deprecated {
+		t.Errorf("Expected the route meta on the operation, got summary %q, tags %v, deprecated %v", put.Summary, put.Tags, put.Deprecated)
+	}
+
+	del := document.Paths["/api/v1/users/{id}"]["delete"]
+	if !reflect.DeepEqual(del.Security, []map[string][]string{{"authorization": {}}}) {
+		t.Errorf("Expected authorization security on a protected route, got %v", del.Security)
+	}
+	if !reflect.DeepEqual(del.RequiredPermissions, []Permission{2}) {
+		t.Errorf("Expected required permissions [2], got %v", del.RequiredPermissions)
+	}
+	if _, ok := del.Responses["401"]; !ok {
+		t.Error("Expected a 401 response on a protected route")
+	}
+	if document.Components.SecuritySchemes["authorization"]["scheme"] != "bearer" {
+		t.Errorf("Expected a bearer security scheme, got %v", document.Components.SecuritySchemes)
+	}
+
+	post := document.Paths["/api/v1/users"]["post"]
+	var requestBody struct {
+		Content map[string]struct {
+			Schema struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"schema"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(post.RequestBody, &requestBody); err != nil {
+		t.Fatalf("Expected a request body, got error %v", err)
+	}
+	properties := requestBody.Content["application/json"].Schema.Properties
+	if properties["id"]["type"] != "integer" || properties["tags"]["type"] != "array" || properties["created"]["format"] != "date-time" {
+		t.Errorf("Expected the request schema to describe openAPITestUser, got %v", properties)
+	}
+	if _, ok := properties["Password"]; ok {
+		t.Error("Expected fields tagged json:\"-\" to be omitted")
+	}
+}
+
+func TestGenerateOpenAPITimestamp(t *testing.T) {
+	originalFormat := timestampFormat
+	defer SetTimestampFormat(originalFormat)
+
+	users := &Router{BasePath: "/users"}
+	HandleFuncTyped(users, "POST", "/", func(ctx context.Context, in openAPITestUser, routeContext *RouteContext) (openAPITestUser, error) {
+		return in, nil
+	})
+	multiRouter, err := NewMultiRouter("/api", []*Router{users})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		layout         string
+		expectedType   string
+		expectedFormat string
+	}{
+		{"", "integer", ""},
+		{time.RFC3339, "string", "date-time"},
+		{time.RFC3339Nano, "string", "date-time"},
+		{time.RFC1123, "string", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			SetTimestampFormat(tt.layout)
+			encoded, err := GenerateOpenAPI(multiRouter)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var document struct {
+				Paths map[string]map[string]struct {
+					Responses map[string]struct {
+						Content map[string]struct {
+							Schema struct {
+								Properties map[string]map[string]interface{} `json:"properties"`
+							} `json:"schema"`
+						} `json:"content"`
+					} `json:"responses"`
+				} `json:"paths"`
+			}
+			if err := json.Unmarshal(encoded, &document); err != nil {
+				t.Fatal(err)
+			}
+			timestamp := document.Paths["/api/users"]["post"].Responses["200"].Content["application/json"].Schema.Properties["timestamp"]
+			if timestamp["type"] != tt.expectedType {
+				t.Errorf("Expected type %q, got %v", tt.expectedType, timestamp["type"])
+			}
+			if format, _ := timestamp["format"].(string); format != tt.expectedFormat {
+				t.Errorf("Expected format %q, got %q", tt.expectedFormat, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package restapi
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+const (
+	defaultPaginationLimit    = 20
+	defaultPaginationMaxLimit = 100
+)
+
+// PaginationOptions configures how ParsePagination reads and validates pagination query parameters
+type PaginationOptions struct {
+	// DefaultLimit is used when no limit is given. Defaults to 20.
+	DefaultLimit int
+	// MaxLimit caps the limit. Defaults to 100.
+	MaxLimit int
+	// SortFields lists the fields allowed in the sort parameter. Sorting is rejected if empty.
+	SortFields []string
+	// DefaultSort is used when no sort field is given
+	DefaultSort string
+}
+
+// Pagination holds validated pagination parameters
+type Pagination struct {
+	Page   int
+	Limit  int
+	Offset int
+	Sort   string
+	// Order is either "asc" or "desc"
+	Order string
+}
+
+// ParsePagination reads the page (or offset), per_page (or page_size or limit), sort and order
+// query parameters. The limit is clamped to opts.MaxLimit. Invalid values return an HTTPError
+// with status 400.
+func ParsePagination(r *http.Request, opts PaginationOptions) (Pagination, error) {
+	if opts.DefaultLimit <= 0 {
+		opts.DefaultLimit = defaultPaginationLimit
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = defaultPaginationMaxLimit
+	}
+	query := r.URL.Query()
+	pagination := Pagination{Page: 1, Limit: opts.DefaultLimit, Sort: opts.DefaultSort, Order: "asc"}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return Pagination{}, NewHTTPError(http.StatusBadRequest, "page must be a positive integer")
+		}
+		pagination.Page = page
+	}
+
+	limitParam := "per_page"
+	for _, param := range []string{"per_page", "page_size", "limit"} {
+		if query.Get(param) != "" {
+			limitParam = param
+			break
+		}
+	}
+	if value := query.Get(limitParam); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return Pagination{}, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be a positive integer", limitParam))
+		}
+		pagination.Limit = limit
+	}
+	if pagination.Limit > opts.MaxLimit {
+		pagination.Limit = opts.MaxLimit
+	}
+	// bound the page so that neither the offset nor the end of the page overflows
+	maxPage := math.MaxInt / pagination.Limit
+	if pagination.Page > maxPage {
+		return Pagination{}, NewHTTPError(http.StatusBadRequest, "page is too large")
+	}
+	pagination.Offset = (pagination.Page - 1) * pagination.Limit
+
+	if value := query.Get("offset"); value != "" {
+		if query.Get("page") != "" {
+			return Pagination{}, NewHTTPError(http.StatusBadRequest, "page and offset can't be combined")
+		}
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return Pagination{}, NewHTTPError(http.StatusBadRequest, "offset must be a non-negative integer")
+		}
+		if offset/pagination.Limit >= maxPage {
+			return Pagination{}, NewHTTPError(http.StatusBadRequest, "offset is too large")
+		}
+		pagination.Offset = offset
+		pagination.Page = offset/pagination.Limit + 1
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		allowed := false
+		for _, field := range opts.SortFields {
+			if field == sort {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return Pagination{}, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("sorting by '%s' is not supported", sort))
+		}
+		pagination.Sort = sort
+	}
+
+	switch order := query.Get("order"); order {
+	case "":
+	case "asc", "desc":
+		pagination.Order = order
+	default:
+		return Pagination{}, NewHTTPError(http.StatusBadRequest, "order must be 'asc' or 'desc'")
+	}
+	return pagination, nil
+}
+
+// PageInfo describes the page of a paginated list response
+type PageInfo struct {
+	Total    int `json:"total"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+}
+
+// WriteJSONPaginated writes data like WriteJSON and adds page to the meta field of the
+// default response template. Custom response formatters don't get the page info. Nil data or a nil
+// slice is an empty page, which is written as an empty list with 200 rather than 204 No Content.
+func WriteJSONPaginated(w http.ResponseWriter, data interface{}, page PageInfo) error {
+	if value := reflect.ValueOf(data); isNilData(data) || (value.Kind() == reflect.Slice && value.IsNil()) {
+		data = []interface{}{}
+	}
+	return writeJSON(w, "application/json", data, true, page)
+}
@@ -0,0 +1,139 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	opts := PaginationOptions{MaxLimit: 50, SortFields: []string{"name", "created"}, DefaultSort: "created"}
+
+	t.Run("Uses defaults", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users", nil)
+		pagination, err := ParsePagination(req, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Pagination{Page: 1, Limit: 20, Offset: 0, Sort: "created", Order: "asc"}
+		if pagination != expected {
+			t.Errorf("Expected %+v, got %+v", expected, pagination)
+		}
+	})
+
+	t.Run("Parses valid parameters", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users?page=3&per_page=10&sort=name&order=desc", nil)
+		pagination, err := ParsePagination(req, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Pagination{Page: 3, Limit: 10, Offset: 20, Sort: "name", Order: "desc"}
+		if pagination != expected {
+			t.Errorf("Expected %+v, got %+v", expected, pagination)
+		}
+	})
+
+	t.Run("Parses page_size and offset", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users?page_size=10&offset=30", nil)
+		pagination, err := ParsePagination(req, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Pagination{Page: 4, Limit: 10, Offset: 30, Sort: "created", Order: "asc"}
+		if pagination != expected {
+			t.Errorf("Expected %+v, got %+v", expected, pagination)
+		}
+	})
+
+	t.Run("Clamps the limit to the maximum", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users?limit=1000", nil)
+		pagination, err := ParsePagination(req, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pagination.Limit != 50 {
+			t.Errorf("Expected limit 50, got %d", pagination.Limit)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Rejects an invalid sort field", "sort=password"},
+		{"Rejects a non-numeric page", "page=abc"},
+		{"Rejects a zero page", "page=0"},
+		{"Rejects a negative limit", "limit=-5"},
+		{"Rejects an invalid order", "order=sideways"},
+		{"Rejects a negative offset", "offset=-1"},
+		{"Rejects page combined with offset", "page=2&offset=10"},
+		{"Rejects a page whose offset overflows", "page=9223372036854775807"},
+		{"Rejects a page overflowing with the limit", "page=461168601842738791&limit=20"},
+		{"Rejects an offset whose page overflows", "offset=9223372036854775807&limit=1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users?"+test.query, nil)
+			_, err := ParsePagination(req, opts)
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Status != http.StatusBadRequest {
+				t.Errorf("Expected a 400 HTTPError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteJSONPaginated(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := WriteJSONPaginated(w, []string{"a", "b"}, PageInfo{Total: 12, Page: 2, PageSize: 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var response struct {
+		Data []string `json:"data"`
+		Meta PageInfo `json:"meta"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Expected JSON body, got error %v", err)
+	}
+	if len(response.Data) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(response.Data))
+	}
+	expected := PageInfo{Total: 12, Page: 2, PageSize: 2}
+	if response.Meta != expected {
+		t.Errorf("Expected meta %+v, got %+v", expected, response.Meta)
+	}
+
+	// an empty page is written as an empty list with its page info
+	for _, data := range []interface{}{nil, []string(nil)} {
+		w := httptest.NewRecorder()
+		if err := WriteJSONPaginated(w, data, PageInfo{Total: 0, Page: 1, PageSize: 20}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		var response struct {
+			Data []string  `json:"data"`
+			Meta *PageInfo `json:"meta"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Expected JSON body, got error %v", err)
+		}
+		if response.Data == nil || len(response.Data) != 0 {
+			t.Errorf("Expected an empty list for %#v, got %v", data, response.Data)
+		}
+		if response.Meta == nil || response.Meta.PageSize != 20 {
+			t.Errorf("Expected the page info, got %+v", response.Meta)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	WriteJSON(w, "ok")
+	if strings.Contains(w.Body.String(), "meta") {
+		t.Errorf("Expected no meta field, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,45 @@
+package restapi
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// PreloadResource is a resource the client should load early, e.g. a stylesheet
+type PreloadResource struct {
+	// Path is the path of the resource, e.g. "/static/style.css"
+	Path string
+	// As is the type of the resource, e.g. "style", "script" or "font"
+	As string
+}
+
+// Preload hints resources to the client. Resources are pushed with HTTP/2 Server Push when
+// the connection supports it, otherwise a "Link: <path>; rel=preload" header is added.
+// Call it before writing the response.
+func Preload(w http.ResponseWriter, resources []PreloadResource) {
+	pusher := findPusher(w)
+	for _, resource := range resources {
+		if pusher != nil && pusher.Push(resource.Path, nil) == nil {
+			continue
+		}
+		link := fmt.Sprintf("<%s>; rel=preload", resource.Path)
+		if resource.As != "" {
+			link += "; as=" + resource.As
+		}
+		w.Header().Add("Link", link)
+	}
+}
+
+// findPusher returns the http.Pusher of w or of a ResponseWriter it wraps, or nil
+func findPusher(w http.ResponseWriter) http.Pusher {
+	for {
+		if pusher, ok := w.(http.Pusher); ok {
+			return pusher
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+}
@@ -0,0 +1,51 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type pushingWriter struct {
+	http.ResponseWriter
+	pushed []string
+}
+
+func (pw *pushingWriter) Push(target string, opts *http.PushOptions) error {
+	pw.pushed = append(pw.pushed, target)
+	return nil
+}
+
+func TestPreload(t *testing.T) {
+	resources := []PreloadResource{
+		{Path: "/static/style.css", As: "style"},
+		{Path: "/static/app.js", As: "script"},
+	}
+
+	t.Run("Falls back to Link headers without push support", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Preload(w, resources)
+
+		expected := []string{"</static/style.css>; rel=preload; as=style", "</static/app.js>; rel=preload; as=script"}
+		if links := w.Header().Values("Link"); !reflect.DeepEqual(links, expected) {
+			t.Errorf("Expected Link headers %v, got %v", expected, links)
+		}
+	})
+
+	t.Run("Pushes through the router's writer", func(t *testing.T) {
+		pusher := &pushingWriter{ResponseWriter: httptest.NewRecorder()}
+		router := &Router{}
+		router.HandleFunc("GET", "/page", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			Preload(w, resources)
+		})
+		router.ServeHTTP(pusher, httptest.NewRequest("GET", "/page", nil))
+
+		if !reflect.DeepEqual(pusher.pushed, []string{"/static/style.css", "/static/app.js"}) {
+			t.Errorf("Expected resources to be pushed, got %v", pusher.pushed)
+		}
+		if links := pusher.Header().Values("Link"); len(links) != 0 {
+			t.Errorf("Expected no Link headers, got %v", links)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package restapi
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAbsoluteURL(t *testing.T) {
+	defer SetTrustedProxies(nil)
+
+	t.Run("Direct request uses request scheme and host", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Host = "api.example.com"
+		if url := AbsoluteURL(req, "/users/1"); url != "http://api.example.com/users/1" {
+			t.Errorf("Expected 'http://api.example.com/users/1', got '%s'", url)
+		}
+
+		req.TLS = &tls.ConnectionState{}
+		if url := AbsoluteURL(req, "users/1"); url != "https://api.example.com/users/1" {
+			t.Errorf("Expected 'https://api.example.com/users/1', got '%s'", url)
+		}
+	})
+
+	t.Run("Forwarded headers are honored from a trusted proxy", func(t *testing.T) {
+		if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.RemoteAddr = "10.1.2.3:4567"
+		req.Host = "internal-host:8080"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "api.example.com, internal-host")
+
+		if url := AbsoluteURL(req, "/users/1"); url != "https://api.example.com/users/1" {
+			t.Errorf("Expected 'https://api.example.com/users/1', got '%s'", url)
+		}
+	})
+
+	t.Run("Forwarded headers are ignored from an untrusted peer", func(t *testing.T) {
+		if err := SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.RemoteAddr = "203.0.113.9:4567"
+		req.Host = "internal-host:8080"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "evil.example.com")
+
+		if url := AbsoluteURL(req, "/users/1"); url != "http://internal-host:8080/users/1" {
+			t.Errorf("Expected 'http://internal-host:8080/users/1', got '%s'", url)
+		}
+	})
+
+	t.Run("Invalid trusted proxy is rejected", func(t *testing.T) {
+		if err := SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+			t.Error("Expected error for invalid trusted proxy")
+		}
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	defer SetTrustedProxies(nil)
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.10"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{"Direct request", "203.0.113.7:4567", "", "203.0.113.7"},
+		{"Forwarded header from an untrusted peer is ignored", "203.0.113.7:4567", "198.51.100.1", "203.0.113.7"},
+		{"Forwarded header from a trusted proxy", "10.1.2.3:4567", "198.51.100.1", "198.51.100.1"},
+		{"Spoofed addresses left of the client are ignored", "10.1.2.3:4567", "1.2.3.4, 198.51.100.1, 192.168.1.10", "198.51.100.1"},
+		{"Only trusted proxies in the chain", "10.1.2.3:4567", "10.0.0.5", "10.0.0.5"},
+		{"Malformed forwarded address", "10.1.2.3:4567", "unknown", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if ip := ClientIP(req); ip != tt.expectedIP {
+				t.Errorf("Expected client IP '%s', got '%s'", tt.expectedIP, ip)
+			}
+		})
+	}
+}
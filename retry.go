@@ -0,0 +1,50 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type retryConfig struct {
+	retryable func(error) bool
+}
+
+// RetryOption configures WithRetry
+type RetryOption func(*retryConfig)
+
+// RetryIf sets the predicate deciding whether an error is retried. By default every error is retried.
+func RetryIf(retryable func(error) bool) RetryOption {
+	return func(config *retryConfig) {
+		config.retryable = retryable
+	}
+}
+
+// WithRetry calls fn up to attempts times until it succeeds. The wait between attempts starts at
+// backoff and doubles after each attempt. Waiting stops when ctx is done, e.g. when the client goes
+// away or the request deadline passes, in which case the context error joined with the last error
+// of fn is returned. Only use it for idempotent operations.
+func WithRetry(ctx context.Context, attempts int, backoff time.Duration, fn func() error, opts ...RetryOption) error {
+	config := retryConfig{retryable: func(error) bool { return true }}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= attempts || !config.retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,78 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	errUnavailable := errors.New("downstream unavailable")
+
+	t.Run("Succeeds after retries", func(t *testing.T) {
+		calls := 0
+		err := WithRetry(context.Background(), 3, time.Millisecond, func() error {
+			calls++
+			if calls < 3 {
+				return errUnavailable
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected success, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		calls := 0
+		err := WithRetry(context.Background(), 3, time.Millisecond, func() error {
+			calls++
+			return errUnavailable
+		})
+		if !errors.Is(err, errUnavailable) {
+			t.Errorf("Expected the last error, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("Does not retry errors rejected by the predicate", func(t *testing.T) {
+		calls := 0
+		errInvalid := errors.New("invalid request")
+		err := WithRetry(context.Background(), 3, time.Millisecond, func() error {
+			calls++
+			return errInvalid
+		}, RetryIf(func(err error) bool { return errors.Is(err, errUnavailable) }))
+		if !errors.Is(err, errInvalid) {
+			t.Errorf("Expected errInvalid, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("Expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("Stops waiting when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		calls := 0
+		start := time.Now()
+		err := WithRetry(ctx, 5, time.Second, func() error {
+			calls++
+			return errUnavailable
+		})
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errUnavailable) {
+			t.Errorf("Expected the deadline and the last error, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("Expected 1 call, got %d", calls)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected to stop at the deadline, took %v", elapsed)
+		}
+	})
+}
@@ -1,15 +1,14 @@
 package restapi
 
 import (
+	"net/http"
 	"testing"
 )
 
 func TestRouteParamsIssues(t *testing.T) {
-	// Test potential issue: RouteParams doesn't have a Set method
-	t.Run("RouteParams missing Set method", func(t *testing.T) {
+	t.Run("RouteParams Set and Get", func(t *testing.T) {
 		params := make(RouteParams)
-		// Manual setting (set manually since there's no Set method. Set method is only used by internal router logic)
-		params["user_id"] = "123"
+		params.Set("user_id", "123")
 		userId, err := params.Get("user_id")
 		if err != nil || userId != "123" {
 			t.Errorf("Expected user_id '123', got: %s, error: %v", userId, err)
@@ -17,4 +16,59 @@ func TestRouteParamsIssues(t *testing.T) {
 
 	})
 
+	t.Run("RouteParams Set on nil map does not panic", func(t *testing.T) {
+		var params RouteParams
+		params.Set("user_id", "123")
+		if _, err := params.Get("user_id"); err == nil {
+			t.Error("Expected error getting a parameter from a nil RouteParams")
+		}
+	})
+
+}
+
+func TestBindParams(t *testing.T) {
+	type userParams struct {
+		ID      int64   `param:"id"`
+		Name    string  `param:"name"`
+		Active  bool    `param:"active"`
+		Score   float64 `param:"score"`
+		Ignored string
+	}
+
+	tests := []struct {
+		name           string
+		params         RouteParams
+		expected       userParams
+		expectedStatus int
+	}{
+		{"Binds all types", RouteParams{"id": "42", "name": "john", "active": "true", "score": "1.5"}, userParams{ID: 42, Name: "john", Active: true, Score: 1.5}, 0},
+		{"Malformed parameter", RouteParams{"id": "abc", "name": "john", "active": "true", "score": "1"}, userParams{}, http.StatusBadRequest},
+		{"Missing parameter", RouteParams{"id": "42"}, userParams{}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params userParams
+			err := BindParams(&RouteContext{Params: &tt.params}, &params)
+			if tt.expectedStatus == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if params != tt.expected {
+					t.Errorf("Expected %+v, got %+v", tt.expected, params)
+				}
+				return
+			}
+			if status := StatusForError(err); status != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d (%v)", tt.expectedStatus, status, err)
+			}
+		})
+	}
+
+	t.Run("Rejects non-struct targets", func(t *testing.T) {
+		var id int
+		if err := BindParams(&RouteContext{Params: &RouteParams{}}, &id); err == nil {
+			t.Error("Expected error binding into a non-struct")
+		}
+	})
 }
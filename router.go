@@ -1,9 +1,15 @@
 package restapi
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"errors"
 )
@@ -12,8 +18,33 @@ type Permission uint
 type RouteContext struct {
 	Params              *RouteParams
 	userId              string
+	userPermissions     []Permission
 	requiredPermissions []Permission
 	CustomData          *CustomData
+	routerMetadata      map[string]interface{}
+	warnings            []Warning
+	ctx                 context.Context
+	status              int
+	deferred            []func()
+	// route is the method and pattern of the matched route, e.g. "GET /users/:id"
+	route string
+	// wildcardPath is the rest of the path captured by a "*name" segment
+	wildcardPath string
+	// matchedPrefix is the request path up to the wildcard, or the whole path
+	matchedPrefix string
+}
+
+// WildcardPath returns the rest of the path captured by the trailing "*name" segment of the
+// matched route, e.g. "css/app.css" for "/static/*file". It is empty for routes without a wildcard.
+func (rc *RouteContext) WildcardPath() string {
+	return rc.wildcardPath
+}
+
+// MatchedPrefix returns the request path matched by the route up to its wildcard, including the
+// base path of a serving MultiRouter, e.g. "/api/static/" for "/api/static/css/app.css", so that
+// MatchedPrefix() + WildcardPath() rebuilds the path. For routes without a wildcard it is the whole path.
+func (rc *RouteContext) MatchedPrefix() string {
+	return rc.matchedPrefix
 }
 
 func (rc *RouteContext) HasRequiredPermissions(userPermissions []Permission) (hasAllPermissions bool) {
@@ -55,6 +86,80 @@ func (rc *RouteContext) SetUserId(userId string) {
 	rc.userId = userId
 }
 
+// GetUserPermissions returns the permissions of the authenticated user, e.g. set by BasicAuthMiddleware
+func (rc *RouteContext) GetUserPermissions() []Permission {
+	return rc.userPermissions
+}
+
+// SetUserPermissions stores the permissions of the authenticated user for the permission check
+func (rc *RouteContext) SetUserPermissions(permissions []Permission) {
+	rc.userPermissions = permissions
+}
+
+// Defer registers fn to run after the handler returns, also when it panics, e.g. to roll back
+// a transaction opened by a middleware. Functions run in reverse order of registration.
+func (rc *RouteContext) Defer(fn func()) {
+	rc.deferred = append(rc.deferred, fn)
+}
+
+// runDeferred runs the functions registered with Defer. Each of them runs even if a previous one panics.
+func (rc *RouteContext) runDeferred() {
+	for _, fn := range rc.deferred {
+		defer fn()
+	}
+}
+
+// Logger returns a logger writing to the output of the standard logger that prefixes each line with
+// the trace ID set by TracingRouter, the request ID set by RequestIDRouter and the matched route
+func (rc *RouteContext) Logger() *log.Logger {
+	var prefix strings.Builder
+	if traceID, ok := rc.Context().Value(contextKeyTraceID).(string); ok {
+		prefix.WriteString("trace_id=" + traceID + " ")
+	}
+	if requestID := GetRequestID(rc.Context()); requestID != "" {
+		prefix.WriteString("request_id=" + requestID + " ")
+	}
+	if rc.route != "" {
+		prefix.WriteString(`route="` + rc.route + `" `)
+	}
+	return log.New(log.Writer(), prefix.String(), log.Flags()|log.Lmsgprefix)
+}
+
+// SetStatus sets the status code the response helpers such as WriteJSON respond with instead
+// of their default, e.g. 201 after creating a resource
+func (rc *RouteContext) SetStatus(statusCode int) {
+	rc.status = statusCode
+}
+
+// Context returns the context of the request, which carries the values of Router.BaseContext.
+// Handlers can use it to honor cancellation and deadlines, including ones set by the
+// authorization and permission middlewares.
+func (rc *RouteContext) Context() context.Context {
+	if rc.ctx == nil {
+		return context.Background()
+	}
+	return rc.ctx
+}
+
+// baseValueContext is a request context that falls back to a base context for values.
+// Cancellation and deadlines come from the request context only.
+type baseValueContext struct {
+	context.Context
+	base context.Context
+}
+
+func (c baseValueContext) Value(key interface{}) interface{} {
+	if value := c.Context.Value(key); value != nil {
+		return value
+	}
+	return c.base.Value(key)
+}
+
+// RouterMetadata returns the Metadata of the MultiRouter that served the request, or nil
+func (rc *RouteContext) RouterMetadata() map[string]interface{} {
+	return rc.routerMetadata
+}
+
 type RouteParams map[string]string
 
 func (rp RouteParams) Get(key string) (string, error) {
@@ -65,6 +170,81 @@ func (rp RouteParams) Get(key string) (string, error) {
 	return value, nil
 }
 
+// Set stores a route parameter. It is a no-op on a nil RouteParams.
+func (rp RouteParams) Set(key, value string) {
+	if rp == nil {
+		return
+	}
+	rp[key] = value
+}
+
+// BindParams sets the fields of the struct v points to from the route parameters named by their
+// `param` tags, e.g. `param:"id"`. Strings, booleans, integers and floats are supported. A missing
+// or malformed parameter results in a 400 HTTPError, so the error can be passed to WriteError.
+func BindParams(ctx *RouteContext, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a non-nil pointer to a struct")
+	}
+	var params RouteParams
+	if ctx != nil && ctx.Params != nil {
+		params = *ctx.Params
+	}
+	target = target.Elem()
+	for i := 0; i < target.NumField(); i++ {
+		name := target.Type().Field(i).Tag.Get("param")
+		if name == "" {
+			continue
+		}
+		value, err := params.Get(name)
+		if err != nil {
+			return NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := setFieldFromString(target.Field(i), value); err != nil {
+			if errors.Is(err, strconv.ErrSyntax) || errors.Is(err, strconv.ErrRange) {
+				return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid value %q for parameter %s", value, name))
+			}
+			return fmt.Errorf("parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFieldFromString converts value to the type of field and sets it
+func setFieldFromString(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
 type CustomData map[string]interface{}
 
 func (cd CustomData) Get(key string) (interface{}, error) {
@@ -81,12 +261,63 @@ func (cd CustomData) Set(key string, value interface{}) {
 
 type RouteHandlerFunc func(http.ResponseWriter, *http.Request, *RouteContext)
 
+// RouteGuard checks a request to a protected route. Returning an error stops the chain and the
+// router writes the response, so a guard never has to remember to return after writing an error.
+type RouteGuard func(r *http.Request, context *RouteContext) error
+
+// guardMiddleware adapts a RouteGuard to the middleware signature. Guard errors are written with
+// their HTTPError or registered status and message, or with statusCode and its status text.
+func guardMiddleware(guard RouteGuard, statusCode int) func(context *RouteContext, handler http.Handler) http.Handler {
+	return func(context *RouteContext, handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := guard(r, context); err != nil {
+				if status, mapped := lookupErrorStatus(err); mapped {
+					writeErrorResponse(w, err, status, true)
+					return
+				}
+				writeErrorResponse(w, err, statusCode, false)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
 type Route struct {
 	Method              string
 	RelativePath        string
 	RequiredPermissions []Permission
 	Handler             RouteHandlerFunc
 	Protected           bool
+	// RequestType and ResponseType are the declared body types of routes registered with HandleFuncTyped
+	RequestType  reflect.Type
+	ResponseType reflect.Type
+	// EnabledEnvironments restricts the route to the given environments, see SetEnvironment.
+	// Requests to the route in other environments get 404. Empty means all environments.
+	EnabledEnvironments []string
+	// Meta documents the route, see HandleFuncWithMeta
+	Meta RouteMeta
+	// constraints holds the compiled regular expressions of ":name(regex)" segments keyed by segment
+	constraints map[string]*regexp.Regexp
+}
+
+// RouteMeta documents a route, e.g. in ListRouteInfo and GenerateOpenAPI
+type RouteMeta struct {
+	Summary string
+	Tags    []string
+	// Deprecated routes respond with a "Deprecation: true" header
+	Deprecated bool
+	// Sunset is the date the route will stop working, sent in a Sunset header when set
+	Sunset time.Time
+}
+
+// RouteInfo describes a registered route, e.g. for generating documentation
+type RouteInfo struct {
+	Method              string
+	Path                string
+	Protected           bool
+	RequiredPermissions []Permission
+	Meta                RouteMeta
 }
 
 type Router struct {
@@ -95,57 +326,191 @@ type Router struct {
 	AuthorizationMiddleware func(context *RouteContext, handler http.Handler) http.Handler
 	PermissionMiddleware    func(context *RouteContext, handler http.Handler) http.Handler
 	CORSConfig              *CORSConfig
+	// AuthorizationGuard is an alternative to AuthorizationMiddleware. When set, it is used instead
+	// and an error it returns results in 401.
+	AuthorizationGuard RouteGuard
+	// PermissionGuard is an alternative to PermissionMiddleware. When set, it is used instead
+	// and an error it returns results in 403.
+	PermissionGuard RouteGuard
+	// IgnoreTrailingSlash makes a route match regardless of a trailing slash, so that
+	// "/users" and "/users/" are treated as the same path. When false (default),
+	// the trailing slash is significant.
+	IgnoreTrailingSlash bool
+	// RedirectSlash redirects a request whose path differs from a registered
+	// route only by a trailing slash to the canonical route path instead of
+	// responding with 404.
+	RedirectSlash bool
+	// HideProtectedRoutes responds with 404 instead of 401 when the AuthorizationMiddleware
+	// rejects a request to a protected route, so that unauthenticated callers can't tell
+	// the route exists. Authenticated requests lacking permissions still get 403.
+	HideProtectedRoutes bool
+	// BaseContext optionally returns a context carrying shared values, e.g. a database handle,
+	// that become visible through the request context and RouteContext.Context.
+	// It mirrors http.Server.BaseContext.
+	BaseContext func() context.Context
+	// MaxPathLength rejects requests whose URL path is longer than MaxPathLength bytes with
+	// 414 URI Too Long before CORS handling and route matching. Zero means no limit.
+	MaxPathLength int
+	// MaxHeaderSize rejects requests whose headers total more than MaxHeaderSize bytes with
+	// 431 Request Header Fields Too Large before CORS handling and route matching. Zero means no limit.
+	MaxHeaderSize int
+	// MethodOverride serves POST requests as the PUT, PATCH or DELETE given in the
+	// X-HTTP-Method-Override header or the _method query parameter, for clients behind
+	// proxies that only allow GET and POST.
+	MethodOverride bool
+	// CaseInsensitive matches the static segments of routes regardless of case, e.g. for legacy
+	// clients requesting "/API/Users" for "/api/users". Parameters keep the case of the request.
+	CaseInsensitive bool
+
+	middlewares []func(http.Handler) http.Handler
+	// handler is the router wrapped in middlewares, nil if there are none
+	handler http.Handler
 }
 
-func (router *Router) HandleFunc(method, path string, handler RouteHandlerFunc) {
-	fixedPath := strings.TrimRight(router.BasePath, "/") + path
+// Use adds middlewares that wrap every request served by the router, including CORS handling
+// and requests that don't match any route. The first middleware is the outermost one.
+// Middlewares should be added before the router starts serving requests.
+func (router *Router) Use(middlewares ...func(http.Handler) http.Handler) {
+	router.middlewares = append(router.middlewares, middlewares...)
+	router.handler = chainMiddlewares(http.HandlerFunc(router.serveHTTP), router.middlewares)
+}
+
+// chainMiddlewares wraps handler in middlewares, the first of them being the outermost one
+func chainMiddlewares(handler http.Handler, middlewares []func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// fullPath returns path prefixed with the base path of the router, which is the path of "/"
+func (router *Router) fullPath(path string) string {
 	if path == "/" {
-		fixedPath = router.BasePath
+		return router.BasePath
 	}
+	return strings.TrimRight(router.BasePath, "/") + path
+}
+
+func (router *Router) HandleFunc(method, path string, handler RouteHandlerFunc) {
 	route := Route{
 		Method:       method,
-		RelativePath: fixedPath,
+		RelativePath: router.fullPath(path),
 		Handler:      handler,
 		Protected:    false,
 	}
-	router.Routes = append(router.Routes, route)
+	router.addRoute(route)
 }
 
 func (router *Router) HandleProtectedFunc(method, path string, requiredPermissions []Permission, handler RouteHandlerFunc) {
-	fixedPath := strings.TrimRight(router.BasePath, "/") + path
-	if path == "/" {
-		fixedPath = router.BasePath
-	}
 	route := Route{
 		Method:              method,
-		RelativePath:        fixedPath,
+		RelativePath:        router.fullPath(path),
 		Handler:             handler,
 		RequiredPermissions: requiredPermissions,
 		Protected:           true,
 	}
+	router.addRoute(route)
+}
+
+// HandleFuncForEnvironments registers a route that is only served when the environment set with
+// SetEnvironment is one of environments, e.g. a test data seeding endpoint for "staging"
+func (router *Router) HandleFuncForEnvironments(method, path string, environments []string, handler RouteHandlerFunc) {
+	route := Route{
+		Method:              method,
+		RelativePath:        router.fullPath(path),
+		Handler:             handler,
+		EnabledEnvironments: environments,
+	}
+	router.addRoute(route)
+}
+
+// HandleFuncWithMeta registers a route like HandleFunc and attaches meta to it for documentation
+func (router *Router) HandleFuncWithMeta(method, path string, meta RouteMeta, handler RouteHandlerFunc) {
+	route := Route{
+		Method:       method,
+		RelativePath: router.fullPath(path),
+		Handler:      handler,
+		Meta:         meta,
+	}
+	router.addRoute(route)
+}
+
+// validMethods are the HTTP methods routes can be registered for
+var validMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodOptions: true, http.MethodConnect: true, http.MethodTrace: true,
+}
+
+// addRoute compiles the parameter constraints of the route and registers it. It panics if the
+// method is invalid, a constraint is not a valid regular expression or a wildcard is not the last segment.
+func (router *Router) addRoute(route Route) {
+	if !validMethods[route.Method] {
+		panic(fmt.Sprintf("invalid HTTP method %q for route %s", route.Method, route.RelativePath))
+	}
+	segments := strings.Split(route.RelativePath, "/")
+	for i, segment := range segments {
+		if wildcardName(segment) != "" && i != len(segments)-1 {
+			panic(fmt.Sprintf("wildcard must be the last segment in route %s %s", route.Method, route.RelativePath))
+		}
+		_, pattern := parseParamSegment(segment)
+		if pattern == "" {
+			continue
+		}
+		constraint, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("invalid constraint in route %s %s: %v", route.Method, route.RelativePath, err))
+		}
+		if route.constraints == nil {
+			route.constraints = make(map[string]*regexp.Regexp)
+		}
+		route.constraints[segment] = constraint
+	}
 	router.Routes = append(router.Routes, route)
 }
 
+// parseParamSegment splits a ":name(regex)" route segment into the parameter name
+// and the optional regex constraint. The name is empty for static segments.
+func parseParamSegment(segment string) (name, pattern string) {
+	if !strings.HasPrefix(segment, ":") {
+		return "", ""
+	}
+	name = segment[1:]
+	if i := strings.Index(name, "("); i != -1 && strings.HasSuffix(name, ")") {
+		name, pattern = name[:i], name[i+1:len(name)-1]
+	}
+	return name, pattern
+}
+
+// wildcardName returns the parameter name of a "*name" route segment, which matches the rest
+// of the path. The name is empty for other segments.
+func wildcardName(segment string) string {
+	if !strings.HasPrefix(segment, "*") {
+		return ""
+	}
+	return segment[1:]
+}
+
 func (router *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	// Handle CORS only if not already handled (e.g., by MultiRouter)
-	corsAlreadyHandled := w.Header().Get("Access-Control-Allow-Origin") != ""
+	if router.handler != nil {
+		router.handler.ServeHTTP(w, req)
+		return
+	}
+	router.serveHTTP(w, req)
+}
+
+func (router *Router) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if !checkRequestLimits(w, req, router.MaxPathLength, router.MaxHeaderSize) {
+		return
+	}
+	// Handle CORS only if not already handled, e.g. by a wrapping middleware. A MultiRouter always
+	// handles CORS for its routers, also when its policy doesn't allow the origin.
+	_, servedByMultiRouter := req.Context().Value(contextKeyPathPrefix).(string)
+	corsAlreadyHandled := servedByMultiRouter || w.Header().Get("Access-Control-Allow-Origin") != ""
 
 	if !corsAlreadyHandled {
 		// handle CORS
 		if router.CORSConfig == nil {
-			// Default CORS that respects global corsAlwaysOn setting
-			requestOrigin := req.Header.Get("Origin")
-			originHeaderMissing := requestOrigin == ""
-
-			// Only set default CORS if:
-			// 1. Origin header is present, OR
-			// 2. corsAlwaysOn is enabled
-			if !originHeaderMissing || GetCORSAlwaysOn() {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-				w.Header().Set("Access-Control-Allow-Credentials", "false")
-			}
+			applyDefaultCORS(w, req)
 		} else {
 			router.CORSConfig.HandleCORS(w, req)
 		}
@@ -155,52 +520,407 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
-	for _, route := range router.Routes {
-		if req.Method != route.Method {
-			continue
+	if router.MethodOverride {
+		req = overrideMethod(req)
+	}
+
+	// routes of a Router served by a MultiRouter are relative to the MultiRouter base path
+	prefix, _ := req.Context().Value(contextKeyPathPrefix).(string)
+	// the prefix of the request may differ in case from the base path of a CaseInsensitive MultiRouter
+	path, _ := cutPrefixFold(req.URL.Path, prefix)
+	requestPrefix := req.URL.Path[:len(req.URL.Path)-len(path)]
+	route, params := router.match(req.Method, path)
+	if route == nil {
+		if target, ok := router.slashRedirectPath(req.Method, path); ok {
+			redirectToPath(w, req, prefix+target)
+			return
 		}
-		routeSegments := strings.Split(route.RelativePath, "/")
-		pathSegments := strings.Split(req.URL.Path, "/")
-		if len(routeSegments) != len(pathSegments) {
-			continue
+		http.NotFound(w, req)
+		return
+	}
+	if router.BaseContext != nil {
+		req = req.WithContext(baseValueContext{Context: req.Context(), base: router.BaseContext()})
+	}
+	routeContext := &RouteContext{Params: &params, ctx: req.Context(), route: route.Method + " " + prefix + route.RelativePath}
+	routeContext.matchedPrefix = requestPrefix + path
+	if segments := strings.Split(route.RelativePath, "/"); wildcardName(segments[len(segments)-1]) != "" {
+		routeContext.wildcardPath = params[wildcardName(segments[len(segments)-1])]
+		// the wildcard starts after the segments preceding it
+		if pathSegments := strings.SplitN(path, "/", len(segments)); len(pathSegments) == len(segments) {
+			routeContext.matchedPrefix = requestPrefix + strings.Join(pathSegments[:len(segments)-1], "/") + "/"
+		}
+	}
+	// pass required permissions to route context
+	routeContext.requiredPermissions = route.RequiredPermissions
+	// pass custom data to route context
+	customData := make(CustomData)
+	routeContext.CustomData = &customData
+	// let wrapping middlewares know the matched route
+	if info, ok := req.Context().Value(contextKeyRequestInfo).(*requestInfo); ok {
+		info.pattern = prefix + route.RelativePath
+		info.routeContext = routeContext
+	}
+	// pass metadata of the serving MultiRouter to route context
+	if metadata, ok := req.Context().Value(contextKeyRouterMetadata).(map[string]interface{}); ok {
+		routeContext.routerMetadata = metadata
+	}
+
+	defer routeContext.runDeferred()
+
+	// announced before the handler runs, which can still change the headers
+	if route.Meta.Deprecated {
+		w.Header().Set("Deprecation", "true")
+	}
+	if !route.Meta.Sunset.IsZero() {
+		w.Header().Set("Sunset", route.Meta.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	// handlers get a writer that carries the route context for the response helpers
+	sw := &statusWriter{ResponseWriter: w, routeContext: routeContext}
+	start := time.Now()
+	addInFlight(1)
+	defer addInFlight(-1)
+	router.serveRoute(sw, req, route, routeContext)
+	observeRequest(req.Method, prefix+route.RelativePath, sw.status, time.Since(start))
+}
+
+// serveRoute runs the route handler, preceded by the authorization and permission
+// middlewares for protected routes
+func (router *Router) serveRoute(w http.ResponseWriter, req *http.Request, route *Route, routeContext *RouteContext) {
+	if route.Protected {
+		authorizationMiddleware := router.AuthorizationMiddleware
+		if router.AuthorizationGuard != nil {
+			authorizationMiddleware = guardMiddleware(router.AuthorizationGuard, http.StatusUnauthorized)
 		}
-		params := make(RouteParams)
-		routeContext := &RouteContext{Params: &params}
-		match := true
-		for i, routeSegment := range routeSegments {
-			if strings.HasPrefix(routeSegment, ":") {
-				params[routeSegment[1:]] = pathSegments[i]
-			} else if routeSegment != pathSegments[i] {
-				match = false
+		permissionMiddleware := router.PermissionMiddleware
+		if router.PermissionGuard != nil {
+			permissionMiddleware = guardMiddleware(router.PermissionGuard, http.StatusForbidden)
+		}
+		if authorizationMiddleware == nil {
+			http.Error(w, "Router.AuthorizationMiddleware is not set", http.StatusInternalServerError)
+			return
+		}
+		if permissionMiddleware == nil {
+			http.Error(w, "Router.PermissionMiddleware is not set", http.StatusInternalServerError)
+			return
+		}
+		var authWriter http.ResponseWriter = w
+		var hiddenWriter *hiddenRouteWriter
+		if router.HideProtectedRoutes {
+			hiddenWriter = &hiddenRouteWriter{ResponseWriter: w}
+			authWriter = hiddenWriter
+		}
+		authorizationMiddleware(routeContext, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hiddenWriter != nil {
+				// the request is authenticated, responses are no longer hidden
+				hiddenWriter.authenticated = true
+			}
+			permissionMiddleware(routeContext, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// the middlewares may have derived a new request context, e.g. with a deadline
+				routeContext.ctx = r.Context()
+				route.Handler(w, r, routeContext)
+			})).ServeHTTP(w, r)
+		})).ServeHTTP(authWriter, req)
+		return
+	}
+	route.Handler(w, req, routeContext)
+}
+
+// Validate reports routes registered more than once for the same method and path, e.g. after a
+// copy-paste mistake. Only the first of them is ever served. Parameter names are ignored, so
+// "/users/:id" and "/users/:userId" conflict. Routes that only overlap, such as "/users/me" and
+// "/users/:id", are valid as the more specific route takes precedence.
+func (router *Router) Validate() error {
+	var errs []error
+	for i := range router.Routes {
+		route := &router.Routes[i]
+		for j := 0; j < i; j++ {
+			other := &router.Routes[j]
+			if routesConflict(route, other, router.CaseInsensitive, router.IgnoreTrailingSlash) {
+				errs = append(errs, fmt.Errorf("route %s %s is already registered as %s", route.Method, route.RelativePath, other.RelativePath))
 				break
 			}
 		}
-		// pass required permissions to route context
-		routeContext.requiredPermissions = route.RequiredPermissions
-		// pass custom data to route context
-		customData := make(CustomData)
-		routeContext.CustomData = &customData
+	}
+	return errors.Join(errs...)
+}
 
-		if match {
-			if route.Protected {
-				if router.AuthorizationMiddleware == nil {
-					http.Error(w, "Router.AuthorizationMiddleware is not set", http.StatusInternalServerError)
-					return
-				}
-				if router.PermissionMiddleware == nil {
-					http.Error(w, "Router.PermissionMiddleware is not set", http.StatusInternalServerError)
-					return
-				}
-				router.AuthorizationMiddleware(routeContext, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					router.PermissionMiddleware(routeContext, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						route.Handler(w, r, routeContext)
-					})).ServeHTTP(w, r)
-				})).ServeHTTP(w, req)
-				return
+// routesConflict reports whether two routes have the same method and match the same paths in a
+// common environment, so that only one of them is ever served. The templates are compared as they
+// are matched by a router with the given options.
+func routesConflict(a, b *Route, caseInsensitive, ignoreTrailingSlash bool) bool {
+	if a.Method != b.Method || !sharesEnvironment(a, b) {
+		return false
+	}
+	templateA, templateB := a.RelativePath, b.RelativePath
+	if ignoreTrailingSlash {
+		templateA, templateB = trimTrailingSlash(templateA), trimTrailingSlash(templateB)
+	}
+	return normalizeRouteTemplate(templateA, caseInsensitive) == normalizeRouteTemplate(templateB, caseInsensitive)
+}
+
+// sharesEnvironment reports whether two routes are enabled in a common environment
+func sharesEnvironment(a, b *Route) bool {
+	if len(a.EnabledEnvironments) == 0 || len(b.EnabledEnvironments) == 0 {
+		return true
+	}
+	for _, environment := range a.EnabledEnvironments {
+		for _, other := range b.EnabledEnvironments {
+			if environment == other {
+				return true
 			}
-			route.Handler(w, req, routeContext)
-			return
 		}
 	}
-	http.NotFound(w, req)
+	return false
+}
+
+// ListRouteInfo returns the registered routes in registration order
+func (router *Router) ListRouteInfo() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(router.Routes))
+	for _, route := range router.Routes {
+		routes = append(routes, RouteInfo{
+			Method:              route.Method,
+			Path:                route.RelativePath,
+			Protected:           route.Protected,
+			RequiredPermissions: route.RequiredPermissions,
+			Meta:                route.Meta,
+		})
+	}
+	return routes
+}
+
+// StripPrefix returns a handler that removes prefix from the request path before
+// passing the request to the router. This allows mounting a Router under a prefix
+// on a standard http.ServeMux. Requests not starting with prefix get a 404.
+func (router *Router) StripPrefix(prefix string) http.Handler {
+	return http.StripPrefix(strings.TrimSuffix(prefix, "/"), router)
+}
+
+// match returns the most specific route matching the given method and path together
+// with the extracted route parameters. An empty method matches any method.
+// Of several matching routes, the first registered one among the most specific wins.
+func (router *Router) match(method, path string) (*Route, RouteParams) {
+	if router.IgnoreTrailingSlash {
+		path = trimTrailingSlash(path)
+	}
+	var matched *Route
+	var matchedParams RouteParams
+	for i := range router.Routes {
+		route := &router.Routes[i]
+		if method != "" && method != route.Method {
+			continue
+		}
+		if params, ok := router.matchRoute(route, path); ok && (matched == nil || moreSpecific(route, matched)) {
+			matched, matchedParams = route, params
+		}
+	}
+	return matched, matchedParams
+}
+
+// moreSpecific reports whether route a takes precedence over route b when both match a path.
+// Segments are compared from left to right: a static segment wins over a parameter with a
+// constraint, which wins over a parameter without one. So "/users/me" wins over "/users/:id".
+func moreSpecific(a, b *Route) bool {
+	aSegments, bSegments := strings.Split(a.RelativePath, "/"), strings.Split(b.RelativePath, "/")
+	for i := 0; i < len(aSegments) && i < len(bSegments); i++ {
+		aRank, bRank := segmentRank(aSegments[i]), segmentRank(bSegments[i])
+		if aRank != bRank {
+			return aRank > bRank
+		}
+	}
+	return false
+}
+
+// segmentRank ranks a template segment by specificity
+func segmentRank(segment string) int {
+	if wildcardName(segment) != "" {
+		return -1
+	}
+	name, pattern := parseParamSegment(segment)
+	switch {
+	case name == "":
+		return 2
+	case pattern != "":
+		return 1
+	}
+	return 0
+}
+
+// allowedMethods returns the methods of the routes matching path
+func (router *Router) allowedMethods(path string) []string {
+	if router.IgnoreTrailingSlash {
+		path = trimTrailingSlash(path)
+	}
+	var methods []string
+	for i := range router.Routes {
+		if _, ok := router.matchRoute(&router.Routes[i], path); ok {
+			methods = append(methods, router.Routes[i].Method)
+		}
+	}
+	return methods
+}
+
+// matchRoute matches path against a route of the router, taking IgnoreTrailingSlash and the
+// environments the route is enabled in into account
+func (router *Router) matchRoute(route *Route, path string) (RouteParams, bool) {
+	if !route.enabledInCurrentEnvironment() {
+		return nil, false
+	}
+	template := route.RelativePath
+	if router.IgnoreTrailingSlash {
+		template = trimTrailingSlash(template)
+	}
+	return route.matchPath(template, path, router.CaseInsensitive)
+}
+
+// slashRedirectPath returns the request path with its trailing slash added or
+// removed when RedirectSlash is enabled and that form matches a route.
+func (router *Router) slashRedirectPath(method, path string) (string, bool) {
+	if !router.RedirectSlash || path == "/" {
+		return "", false
+	}
+	target := path + "/"
+	if strings.HasSuffix(path, "/") {
+		target = strings.TrimSuffix(path, "/")
+	}
+	if route, _ := router.match(method, target); route != nil {
+		return target, true
+	}
+	return "", false
+}
+
+// redirectToPath redirects the request to path, preserving the query string.
+// GET and HEAD requests get a 301, other methods a 308 so that the method and
+// body are kept by the client.
+func redirectToPath(w http.ResponseWriter, req *http.Request, path string) {
+	target := *req.URL
+	target.Path = path
+	code := http.StatusMovedPermanently
+	if req.Method != "GET" && req.Method != "HEAD" {
+		code = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, req, target.String(), code)
+}
+
+// RedirectSeeOther responds with 303 See Other, redirecting the client to location with a GET request.
+// Use it after a state-changing POST (Post/Redirect/Get) so that refreshing the page doesn't resubmit.
+func RedirectSeeOther(w http.ResponseWriter, location string) {
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// overrideMethod returns a copy of a POST request with the method given in the X-HTTP-Method-Override
+// header or the _method query parameter. Other requests and methods are returned as is.
+func overrideMethod(req *http.Request) *http.Request {
+	if req.Method != "POST" {
+		return req
+	}
+	method := req.Header.Get("X-HTTP-Method-Override")
+	if method == "" {
+		method = req.URL.Query().Get("_method")
+	}
+	switch method = strings.ToUpper(method); method {
+	case "PUT", "PATCH", "DELETE":
+		req = req.WithContext(req.Context())
+		req.Method = method
+	}
+	return req
+}
+
+// checkRequestLimits responds with 414 or 431 and returns false when the path or the headers of req
+// exceed the limits, zero meaning no limit. It runs before any other processing of the request.
+func checkRequestLimits(w http.ResponseWriter, req *http.Request, maxPathLength, maxHeaderSize int) bool {
+	if maxPathLength > 0 && len(req.URL.Path) > maxPathLength {
+		WriteError(w, NewHTTPError(http.StatusRequestURITooLong, ""))
+		return false
+	}
+	if maxHeaderSize > 0 && headerSize(req.Header) > maxHeaderSize {
+		WriteError(w, NewHTTPError(http.StatusRequestHeaderFieldsTooLarge, ""))
+		return false
+	}
+	return true
+}
+
+// headerSize returns the size of the headers as sent on the wire, i.e. "Name: value\r\n" per value
+func headerSize(header http.Header) int {
+	size := 0
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(value) + 4
+		}
+	}
+	return size
+}
+
+// matchPath reports whether path matches the route template segment by
+// segment and returns the parameters captured by ":name" segments. Segments
+// with a regex constraint only match values satisfying it. A trailing "*name"
+// segment captures the rest of the path, which may be empty. With caseInsensitive,
+// static segments match regardless of case.
+func (route *Route) matchPath(template, path string, caseInsensitive bool) (RouteParams, bool) {
+	routeSegments := strings.Split(template, "/")
+	pathSegments := strings.Split(path, "/")
+	last := len(routeSegments) - 1
+	wildcard := wildcardName(routeSegments[last])
+	if wildcard != "" && len(pathSegments) > len(routeSegments) {
+		// the wildcard captures the rest of the path
+		pathSegments = append(pathSegments[:last], strings.Join(pathSegments[last:], "/"))
+	}
+	if len(routeSegments) != len(pathSegments) {
+		return nil, false
+	}
+	params := make(RouteParams)
+	for i, routeSegment := range routeSegments {
+		if i == last && wildcard != "" {
+			params[wildcard] = pathSegments[i]
+		} else if name, _ := parseParamSegment(routeSegment); name != "" {
+			if constraint, ok := route.constraints[routeSegment]; ok && !constraint.MatchString(pathSegments[i]) {
+				return nil, false
+			}
+			params[name] = pathSegments[i]
+		} else if routeSegment != pathSegments[i] && !(caseInsensitive && strings.EqualFold(routeSegment, pathSegments[i])) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// trimTrailingSlash removes a trailing slash from path, leaving the root path intact
+func trimTrailingSlash(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// hiddenRouteWriter turns a 401 written before the request is authenticated into a 404, removing
+// the headers that would reveal the route
+type hiddenRouteWriter struct {
+	http.ResponseWriter
+	authenticated bool
+	hidden        bool
+}
+
+func (hw *hiddenRouteWriter) WriteHeader(statusCode int) {
+	if hw.authenticated || statusCode != http.StatusUnauthorized {
+		hw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	hw.hidden = true
+	hw.Header().Del("WWW-Authenticate")
+	hw.Header().Del("Deprecation")
+	hw.Header().Del("Sunset")
+	http.NotFound(hw.ResponseWriter, nil)
+}
+
+func (hw *hiddenRouteWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+func (hw *hiddenRouteWriter) Write(b []byte) (int, error) {
+	if hw.hidden {
+		// discard the body of the hidden 401 response
+		return len(b), nil
+	}
+	return hw.ResponseWriter.Write(b)
 }
@@ -1,77 +1,38 @@
 package restapi
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"sync"
-	"syscall"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
 
-// create a channel to receive OS signals
-var sigChan chan os.Signal = make(chan os.Signal, 1)
-var handler http.Handler
+// testServedPaths checks that handler serves /full/path/to/test and not /full/path/to/test2
+func testServedPaths(t *testing.T, handler http.Handler) {
+	server := NewTestServer(handler)
+	defer server.Close()
 
-func createAndStartServer(t *testing.T) {
-	// create a server
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: handler,
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/full/path/to/test", http.StatusOK},
+		{"/full/path/to/test2", http.StatusNotFound},
 	}
-	// start the server in a goroutine
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			t.Error(err)
-		}
-	}()
-	client := &http.Client{}
-	client.Timeout = 10 * time.Second
-
-	wg := &sync.WaitGroup{}
-
-	// simulate a simple request
-	wg.Add(1)
-	// expect this handler to be found
-	go func() {
-		resp, err := client.Get("http://localhost:8080/full/path/to/test")
-		if err != nil {
-			t.Error(err)
-		}
-		if resp.StatusCode != http.StatusOK {
-			t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
-		} else {
-			t.Log("Client request successful")
-		}
-		wg.Done()
-	}()
-	wg.Add(1)
-	// expect this handler to NOT be found
-	go func() {
-		resp, err := client.Get("http://localhost:8080/full/path/to/test2")
+	for _, tt := range tests {
+		resp, err := server.DoJSON("GET", tt.path, nil, nil)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		if resp.StatusCode != http.StatusNotFound {
-			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.StatusCode)
-		} else {
-			t.Log("Client request successful")
+		if resp.StatusCode != tt.expected {
+			t.Errorf("GET %s: expected status code %d, got %d", tt.path, tt.expected, resp.StatusCode)
 		}
-		wg.Done()
-	}()
-
-	wg.Wait()
-	sigChan <- syscall.SIGINT
-	// wait for a signal
-	<-sigChan
-
-	// create a context with timeout for the shutdown process
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	// gracefully shut down the server
-	if err := server.Shutdown(ctx); err != nil {
-		t.Fatalf("Server Shutdown Failed:%+v", err)
 	}
 }
 
@@ -82,8 +43,7 @@ func TestCreateRouter(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler = router
-	createAndStartServer(t)
+	testServedPaths(t, router)
 }
 
 func TestCreateMultiRouter(t *testing.T) {
@@ -98,6 +58,821 @@ func TestCreateMultiRouter(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	handler = mr
-	createAndStartServer(t)
+	testServedPaths(t, mr)
+}
+
+func TestIgnoreTrailingSlash(t *testing.T) {
+	newRouter := func(ignoreTrailingSlash bool) *Router {
+		router := &Router{BasePath: "/api", IgnoreTrailingSlash: ignoreTrailingSlash}
+		router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			w.WriteHeader(http.StatusOK)
+		})
+		router.HandleFunc("GET", "/orders/", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			w.WriteHeader(http.StatusOK)
+		})
+		return router
+	}
+
+	tests := []struct {
+		ignoreTrailingSlash bool
+		path                string
+		expected            int
+	}{
+		{false, "/api/users", http.StatusOK},
+		{false, "/api/users/", http.StatusNotFound},
+		{false, "/api/orders", http.StatusNotFound},
+		{true, "/api/users", http.StatusOK},
+		{true, "/api/users/", http.StatusOK},
+		{true, "/api/orders", http.StatusOK},
+		{true, "/api/orders/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		newRouter(tt.ignoreTrailingSlash).ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("IgnoreTrailingSlash=%v GET %s: expected status %d, got %d", tt.ignoreTrailingSlash, tt.path, tt.expected, w.Code)
+		}
+	}
+}
+
+func TestRedirectSlash(t *testing.T) {
+	router := &Router{BasePath: "/api", RedirectSlash: true}
+	router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.HandleFunc("POST", "/orders/", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	t.Run("GET with extra trailing slash redirects with 301", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/users/?page=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("Expected status 301, got %d", w.Code)
+		}
+		if location := w.Header().Get("Location"); location != "/api/users?page=2" {
+			t.Errorf("Expected Location '/api/users?page=2', got '%s'", location)
+		}
+	})
+
+	t.Run("POST with missing trailing slash redirects with 308", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/orders", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("Expected status 308, got %d", w.Code)
+		}
+		if location := w.Header().Get("Location"); location != "/api/orders/" {
+			t.Errorf("Expected Location '/api/orders/', got '%s'", location)
+		}
+	})
+
+	t.Run("Unknown path still returns 404", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/unknown/", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestRegexConstrainedParams(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("GET", `/users/:id(\d+)`, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		id, _ := ctx.Params.Get("id")
+		w.Write([]byte("id=" + id))
+	})
+	router.HandleFunc("GET", "/users/:id/posts/:slug([a-z-]+)", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		slug, _ := ctx.Params.Get("slug")
+		w.Write([]byte("slug=" + slug))
+	})
+
+	tests := []struct {
+		path     string
+		expected int
+		body     string
+	}{
+		{"/users/123", http.StatusOK, "id=123"},
+		{"/users/abc", http.StatusNotFound, ""},
+		{"/users/12a", http.StatusNotFound, ""},
+		{"/users/abc/posts/hello-world", http.StatusOK, "slug=hello-world"},
+		{"/users/abc/posts/Hello", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("GET %s: expected status %d, got %d", tt.path, tt.expected, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET %s: expected body '%s', got '%s'", tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	t.Run("Invalid constraint panics at registration", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic for invalid regex constraint")
+			}
+		}()
+		router.HandleFunc("GET", "/broken/:id([)", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	})
+}
+
+func TestRouterStripPrefix(t *testing.T) {
+	router := &Router{BasePath: "/v1"}
+	router.HandleFunc("GET", "/users/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		id, _ := ctx.Params.Get("id")
+		w.Write([]byte(id))
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", router.StripPrefix("/api/"))
+
+	req := httptest.NewRequest("GET", "/api/v1/users/42", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w.Body.String() != "42" {
+		t.Errorf("Expected body '42', got '%s'", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/other/v1/users/42", nil)
+	w = httptest.NewRecorder()
+	router.StripPrefix("/api").ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a path outside the prefix, got %d", w.Code)
+	}
+}
+
+func TestHideProtectedRoutes(t *testing.T) {
+	newRouter := func(hide bool) *Router {
+		router := &Router{HideProtectedRoutes: hide}
+		router.AuthorizationMiddleware = func(context *RouteContext, handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer valid-token" {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				context.SetUserId("user-123")
+				handler.ServeHTTP(w, r)
+			})
+		}
+		router.PermissionMiddleware = func(context *RouteContext, handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !context.HasRequiredPermissions([]Permission{1}) {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+				handler.ServeHTTP(w, r)
+			})
+		}
+		router.HandleProtectedFunc("GET", "/admin", []Permission{2}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			w.WriteHeader(http.StatusOK)
+		})
+		router.HandleProtectedFunc("GET", "/legacy-admin", []Permission{2}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			w.WriteHeader(http.StatusOK)
+		})
+		router.Routes[len(router.Routes)-1].Meta = RouteMeta{Deprecated: true, Sunset: time.Date(2030, 6, 30, 0, 0, 0, 0, time.UTC)}
+		return router
+	}
+
+	t.Run("Anonymous request gets 404 when enabled", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		w := httptest.NewRecorder()
+		newRouter(true).ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+		if w.Header().Get("WWW-Authenticate") != "" {
+			t.Error("Expected WWW-Authenticate header to be removed")
+		}
+		if body := w.Body.String(); body != "404 page not found\n" {
+			t.Errorf("Expected a plain 404 body, got '%s'", body)
+		}
+	})
+
+	t.Run("Hidden deprecated route doesn't announce its deprecation", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/legacy-admin", nil)
+		w := httptest.NewRecorder()
+		newRouter(true).ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+		if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+			t.Errorf("Expected no Deprecation or Sunset header, got '%s' and '%s'", w.Header().Get("Deprecation"), w.Header().Get("Sunset"))
+		}
+	})
+
+	t.Run("Anonymous request gets 401 when disabled", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		w := httptest.NewRecorder()
+		newRouter(false).ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("Authenticated but unauthorized request still gets 403", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		w := httptest.NewRecorder()
+		newRouter(true).ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
+
+func TestRouteGuards(t *testing.T) {
+	newRouter := func() (*Router, *bool) {
+		handlerCalled := false
+		router := &Router{}
+		router.AuthorizationGuard = func(r *http.Request, context *RouteContext) error {
+			if r.Header.Get("Authorization") == "" {
+				return errors.New("missing token")
+			}
+			context.SetUserId("user-123")
+			return nil
+		}
+		router.PermissionGuard = func(r *http.Request, context *RouteContext) error {
+			if r.Header.Get("X-Role") == "suspended" {
+				return NewHTTPError(http.StatusLocked, "account suspended")
+			}
+			if !context.HasRequiredPermissions([]Permission{1}) {
+				return errors.New("missing permission")
+			}
+			return nil
+		}
+		router.HandleProtectedFunc("GET", "/admin", []Permission{1}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			handlerCalled = true
+			w.WriteHeader(http.StatusOK)
+		})
+		router.HandleProtectedFunc("GET", "/super", []Permission{2}, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			handlerCalled = true
+			w.WriteHeader(http.StatusOK)
+		})
+		return router, &handlerCalled
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		headers       map[string]string
+		expected      int
+		handlerCalled bool
+	}{
+		{"Authorization guard short-circuits with 401", "/admin", nil, http.StatusUnauthorized, false},
+		{"Permission guard short-circuits with 403", "/super", map[string]string{"Authorization": "token"}, http.StatusForbidden, false},
+		{"HTTPError from a guard sets the status", "/admin", map[string]string{"Authorization": "token", "X-Role": "suspended"}, http.StatusLocked, false},
+		{"Passing guards reach the handler", "/admin", map[string]string{"Authorization": "token"}, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, handlerCalled := newRouter()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if *handlerCalled != tt.handlerCalled {
+				t.Errorf("Expected handler called to be %v", tt.handlerCalled)
+			}
+		})
+	}
+}
+
+func TestRedirectSeeOther(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("POST", "/orders", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		RedirectSeeOther(w, "/orders/42")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/orders", nil))
+
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("Expected status 303, got %d", w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/orders/42" {
+		t.Errorf("Expected Location '/orders/42', got '%s'", location)
+	}
+}
+
+func TestRouterBaseContext(t *testing.T) {
+	type dbKey struct{}
+	router := &Router{
+		BaseContext: func() context.Context {
+			return context.WithValue(context.Background(), dbKey{}, "primary")
+		},
+	}
+	var fromRouteContext, fromRequest interface{}
+	router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		fromRouteContext = ctx.Context().Value(dbKey{})
+		fromRequest = r.Context().Value(dbKey{})
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+
+	if fromRouteContext != "primary" {
+		t.Errorf("Expected 'primary' from RouteContext.Context, got %v", fromRouteContext)
+	}
+	if fromRequest != "primary" {
+		t.Errorf("Expected 'primary' from the request context, got %v", fromRequest)
+	}
+}
+
+func TestRouterValidate(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {}
+
+	tests := []struct {
+		name        string
+		register    func(router *Router)
+		expectError bool
+	}{
+		{"Distinct routes", func(router *Router) {
+			router.HandleFunc("GET", "/users", handler)
+			router.HandleFunc("POST", "/users", handler)
+			router.HandleFunc("GET", "/users/:id", handler)
+		}, false},
+		{"Same method and path", func(router *Router) {
+			router.HandleFunc("GET", "/users", handler)
+			router.HandleFunc("GET", "/users", handler)
+		}, true},
+		{"Same path with different parameter names", func(router *Router) {
+			router.HandleFunc("GET", "/users/:id", handler)
+			router.HandleProtectedFunc("GET", "/users/:userId", nil, handler)
+		}, true},
+		{"Static and parameter segments are resolved by precedence", func(router *Router) {
+			router.HandleFunc("GET", "/users/:id", handler)
+			router.HandleFunc("GET", "/users/me", handler)
+		}, false},
+		{"Same path in disjoint environments", func(router *Router) {
+			router.HandleFuncForEnvironments("POST", "/seed", []string{"staging"}, handler)
+			router.HandleFuncForEnvironments("POST", "/seed", []string{"development"}, handler)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := &Router{}
+			tt.register(router)
+			if err := router.Validate(); (err != nil) != tt.expectError {
+				t.Errorf("Expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+
+	t.Run("Case conflicts with CaseInsensitive", func(t *testing.T) {
+		router := &Router{CaseInsensitive: true}
+		router.HandleFunc("GET", "/users/:id", handler)
+		router.HandleFunc("GET", "/Users/:userId", handler)
+		if err := router.Validate(); err == nil {
+			t.Error("Expected error for routes differing only in case")
+		}
+	})
+
+	t.Run("Trailing slash conflicts with IgnoreTrailingSlash", func(t *testing.T) {
+		router := &Router{IgnoreTrailingSlash: true}
+		router.HandleFunc("GET", "/users", handler)
+		router.HandleFunc("GET", "/users/", handler)
+		if err := router.Validate(); err == nil {
+			t.Error("Expected error for routes differing only by a trailing slash")
+		}
+	})
+}
+
+func TestStaticSegmentPrecedence(t *testing.T) {
+	var served string
+	handlerFor := func(name string) RouteHandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			served = name
+		}
+	}
+	router := &Router{}
+	// registered from least to most specific
+	router.HandleFunc("GET", "/users/:id", handlerFor("user"))
+	router.HandleFunc("GET", `/users/:id(\d+)`, handlerFor("numeric user"))
+	router.HandleFunc("GET", "/users/me", handlerFor("me"))
+	router.HandleFunc("GET", "/:org/repos", handlerFor("org repos"))
+	router.HandleFunc("GET", "/users/:id/repos", handlerFor("user repos"))
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/users/me", "me"},
+		{"/users/42", "numeric user"},
+		{"/users/john", "user"},
+		{"/users/john/repos", "user repos"},
+		{"/acme/repos", "org repos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			served = ""
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+			if served != tt.expected {
+				t.Errorf("Expected '%s' to serve %s, got '%s'", tt.expected, tt.path, served)
+			}
+		})
+	}
+}
+
+func TestRouteContextContext(t *testing.T) {
+	router := &Router{
+		AuthorizationMiddleware: func(routeContext *RouteContext, handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+				defer cancel()
+				handler.ServeHTTP(w, r.WithContext(ctx))
+			})
+		},
+		PermissionMiddleware: DefaultPermissionMiddleware(nil),
+	}
+	var public, protected context.Context
+	router.HandleFunc("GET", "/public", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		public = ctx.Context()
+	})
+	router.HandleProtectedFunc("GET", "/protected", nil, func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		protected = ctx.Context()
+	})
+
+	t.Run("Returns the request context", func(t *testing.T) {
+		requestCtx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "/public", nil).WithContext(requestCtx)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		cancel()
+		if public == nil || public.Err() != context.Canceled {
+			t.Error("Expected the route context to follow the request's cancellation")
+		}
+	})
+
+	t.Run("Carries deadlines set by middlewares", func(t *testing.T) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/protected", nil))
+		if protected == nil {
+			t.Fatal("Expected handler to be called")
+		}
+		if _, ok := protected.Deadline(); !ok {
+			t.Error("Expected the deadline set by the middleware")
+		}
+	})
+}
+
+func TestRouteContextDefer(t *testing.T) {
+	var calls []string
+	router := &Router{}
+	router.HandleFunc("POST", "/orders", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		ctx.Defer(func() { calls = append(calls, "release connection") })
+		ctx.Defer(func() { calls = append(calls, "rollback") })
+		calls = append(calls, "handler")
+	})
+	router.HandleFunc("POST", "/panics", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		ctx.Defer(func() { calls = append(calls, "rollback") })
+		panic("boom")
+	})
+
+	t.Run("Runs after the handler in reverse order", func(t *testing.T) {
+		calls = nil
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/orders", nil))
+		expected := []string{"handler", "rollback", "release connection"}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("Expected %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("Runs when the handler panics", func(t *testing.T) {
+		calls = nil
+		w := httptest.NewRecorder()
+		RecoveryRouter(router).ServeHTTP(w, httptest.NewRequest("POST", "/panics", nil))
+		if !reflect.DeepEqual(calls, []string{"rollback"}) {
+			t.Errorf("Expected [rollback], got %v", calls)
+		}
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", w.Code)
+		}
+	})
+}
+
+func TestRouterRequestLimits(t *testing.T) {
+	router := &Router{MaxPathLength: 16, MaxHeaderSize: 64}
+	router.HandleFunc("GET", "/items/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		headerValue    string
+		expectedStatus int
+	}{
+		{"Within limits", "GET", "/items/1", "short", http.StatusOK},
+		{"Path too long", "GET", "/items/" + strings.Repeat("1", 20), "short", http.StatusRequestURITooLong},
+		{"Headers too large", "GET", "/items/1", strings.Repeat("x", 64), http.StatusRequestHeaderFieldsTooLarge},
+		{"Path too long for a preflight", "OPTIONS", "/" + strings.Repeat("1", 20), "short", http.StatusRequestURITooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Custom", tt.headerValue)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestRouterMethodOverride(t *testing.T) {
+	router := &Router{MethodOverride: true}
+	for _, method := range []string{"POST", "PUT", "DELETE", "GET"} {
+		method := method
+		router.HandleFunc(method, "/items", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			w.Write([]byte(method))
+		})
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		header         string
+		expectedMethod string
+	}{
+		{"Overrides POST with the header", "POST", "/items", "DELETE", "DELETE"},
+		{"Overrides POST with the query parameter", "POST", "/items?_method=put", "", "PUT"},
+		{"Ignores other methods", "GET", "/items", "DELETE", "GET"},
+		{"Ignores unsupported overrides", "POST", "/items", "GET", "POST"},
+		{"Keeps POST without an override", "POST", "/items", "", "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("X-HTTP-Method-Override", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Body.String() != tt.expectedMethod {
+				t.Errorf("Expected %s handler, got %q", tt.expectedMethod, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		router.MethodOverride = false
+		req := httptest.NewRequest("POST", "/items", nil)
+		req.Header.Set("X-HTTP-Method-Override", "DELETE")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Body.String() != "POST" {
+			t.Errorf("Expected POST handler, got %q", w.Body.String())
+		}
+	})
+}
+
+func TestRouteContextLogger(t *testing.T) {
+	var output bytes.Buffer
+	originalOutput, originalFlags := log.Writer(), log.Flags()
+	log.SetOutput(&output)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(originalOutput)
+		log.SetFlags(originalFlags)
+	}()
+
+	router := &Router{}
+	router.HandleFunc("GET", "/users/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		ctx.Logger().Printf("loading user")
+	})
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	RequestIDRouter(router).ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := `request_id=req-1 route="GET /users/:id" loading user` + "\n"
+	if output.String() != expected {
+		t.Errorf("Expected log line %q, got %q", expected, output.String())
+	}
+}
+
+func TestRouterWildcardRoutes(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("GET", "/api/users/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.Write([]byte("user"))
+	})
+	router.HandleFunc("GET", "/static/*file", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		file, _ := ctx.Params.Get("file")
+		w.Write([]byte("static " + file))
+	})
+	router.HandleFunc("GET", "/*path", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		path, _ := ctx.Params.Get("path")
+		w.Write([]byte("fallback " + path))
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedBody string
+	}{
+		{"Specific route takes precedence", "/api/users/42", "user"},
+		{"Captures the rest of the path", "/static/css/app.css", "static css/app.css"},
+		{"Captures an empty rest", "/static/", "static "},
+		{"Catch-all matches unknown paths", "/dashboard/settings", "fallback dashboard/settings"},
+		{"Catch-all matches the root path", "/", "fallback "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Wildcard must be the last segment", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a panic for a wildcard before the last segment")
+			}
+		}()
+		router.HandleFunc("GET", "/files/*path/edit", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {})
+	})
+}
+
+func TestRouteContextWildcardPath(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.Write([]byte(ctx.MatchedPrefix() + "|" + ctx.WildcardPath()))
+	}
+	proxy := &Router{BasePath: "/proxy"}
+	proxy.HandleFunc("GET", "/:service/*rest", handler)
+	proxy.HandleFunc("GET", "/status", handler)
+	multiRouter, err := NewMultiRouter("/api", []*Router{proxy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		path         string
+		expectedBody string
+	}{
+		{"Wildcard route", proxy, "/proxy/users/v1/users/42", "/proxy/users/|v1/users/42"},
+		{"Empty wildcard", proxy, "/proxy/users/", "/proxy/users/|"},
+		{"Route without wildcard", proxy, "/proxy/status", "/proxy/status|"},
+		{"Served by a MultiRouter", multiRouter, "/api/proxy/users/v1/users", "/api/proxy/users/|v1/users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouterCaseInsensitive(t *testing.T) {
+	router := &Router{BasePath: "/api"}
+	router.HandleFunc("GET", "/users/:name", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		name, _ := ctx.Params.Get("name")
+		w.Write([]byte(name))
+	})
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		path            string
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{"Case-sensitive by default", false, "/API/Users/John", http.StatusNotFound, ""},
+		{"Matches mixed-case paths", true, "/API/Users/John", http.StatusOK, "John"},
+		{"Matches lower-case paths", true, "/api/users/john", http.StatusOK, "john"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router.CaseInsensitive = tt.caseInsensitive
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouterDeprecationHeaders(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		w.WriteHeader(http.StatusOK)
+	}
+	sunset := time.Date(2030, 6, 30, 23, 59, 59, 0, time.FixedZone("CEST", 2*60*60))
+	router := &Router{}
+	router.HandleFuncWithMeta("GET", "/v1/users", RouteMeta{Deprecated: true, Sunset: sunset}, handler)
+	router.HandleFuncWithMeta("GET", "/v1/orders", RouteMeta{Deprecated: true}, handler)
+	router.HandleFunc("GET", "/v2/users", handler)
+
+	tests := []struct {
+		name               string
+		path               string
+		expectedDeprecated string
+		expectedSunset     string
+	}{
+		{"Deprecated route with a sunset date", "/v1/users", "true", "Sun, 30 Jun 2030 21:59:59 GMT"},
+		{"Deprecated route", "/v1/orders", "true", ""},
+		{"Current route", "/v2/users", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if deprecation := w.Header().Get("Deprecation"); deprecation != tt.expectedDeprecated {
+				t.Errorf("Expected Deprecation '%s', got '%s'", tt.expectedDeprecated, deprecation)
+			}
+			if sunset := w.Header().Get("Sunset"); sunset != tt.expectedSunset {
+				t.Errorf("Expected Sunset '%s', got '%s'", tt.expectedSunset, sunset)
+			}
+		})
+	}
+}
+
+func TestRouterUse(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("GET", "/items", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		WriteJSON(w, GetRequestID(r.Context()))
+	})
+	router.Use(RequestIDRouter, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Inner", "true")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/items", nil))
+	if w.Header().Get("X-Inner") != "true" {
+		t.Errorf("Expected the middleware to set X-Inner")
+	}
+	requestID := w.Header().Get("X-Request-ID")
+	if requestID == "" || !strings.Contains(w.Body.String(), requestID) {
+		t.Errorf("Expected the handler to see request ID '%s', got body %s", requestID, w.Body.String())
+	}
+}
+
+func TestRouterRejectsInvalidMethods(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {}
+	tests := []struct {
+		method      string
+		expectPanic bool
+	}{
+		{"PATCH", false},
+		{"TRACE", false},
+		{"GETT", true},
+		{"get", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			defer func() {
+				if recovered := recover(); (recovered != nil) != tt.expectPanic {
+					t.Errorf("Expected panic %v, got %v", tt.expectPanic, recovered)
+				}
+			}()
+			router := &Router{}
+			router.HandleFunc(tt.method, "/users", handler)
+		})
+	}
 }
@@ -0,0 +1,47 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeadersRouter(t *testing.T) {
+	handler := SecurityHeadersRouter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/embed" {
+			w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		}
+		w.WriteHeader(http.StatusOK)
+	}), SecurityHeadersOptions{
+		ContentSecurityPolicy:   "default-src 'none'",
+		StrictTransportSecurity: "-",
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		expected map[string]string
+	}{
+		{"Sets defaults and overrides", "/", map[string]string{
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Content-Security-Policy":   "default-src 'none'",
+			"Strict-Transport-Security": "",
+		}},
+		{"Handlers can override headers", "/embed", map[string]string{
+			"X-Frame-Options": "SAMEORIGIN",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			for name, expected := range tt.expected {
+				if value := w.Header().Get(name); value != expected {
+					t.Errorf("Expected %s '%s', got '%s'", name, expected, value)
+				}
+			}
+		})
+	}
+}
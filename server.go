@@ -0,0 +1,83 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+type serverConfig struct {
+	shutdownTimeout time.Duration
+	configure       []func(*http.Server)
+}
+
+// ServerOption configures the server started by ListenAndServe
+type ServerOption func(*serverConfig)
+
+// WithShutdownTimeout sets how long in-flight requests are given to complete on shutdown. Defaults to 30 seconds.
+func WithShutdownTimeout(timeout time.Duration) ServerOption {
+	return func(config *serverConfig) {
+		config.shutdownTimeout = timeout
+	}
+}
+
+// WithServerConfig allows configuring the underlying http.Server, e.g. its timeouts or TLS config
+func WithServerConfig(configure func(*http.Server)) ServerOption {
+	return func(config *serverConfig) {
+		config.configure = append(config.configure, configure)
+	}
+}
+
+// ListenAndServe starts an HTTP server for handler on addr and gracefully shuts it down on
+// SIGINT or SIGTERM, waiting for in-flight requests to complete up to the shutdown timeout.
+// It returns nil after a graceful shutdown.
+func ListenAndServe(addr string, handler http.Handler, opts ...ServerOption) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serve(ctx, listener, handler, opts...)
+}
+
+// serve serves handler on listener until ctx is done and then shuts the server down gracefully
+func serve(ctx context.Context, listener net.Listener, handler http.Handler, opts ...ServerOption) error {
+	config := serverConfig{shutdownTimeout: defaultShutdownTimeout}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	server := &http.Server{Addr: listener.Addr().String(), Handler: handler}
+	for _, configure := range config.configure {
+		configure(server)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package restapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	router := &Router{}
+	router.HandleFunc("GET", "/slow", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		WriteJSONWithoutTemplate(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, listener, router, WithShutdownTimeout(5*time.Second))
+	}()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		results <- result{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}()
+
+	<-started
+	// shut down while the request is in flight
+	cancel()
+
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("Expected the in-flight request to complete, got %v", res.err)
+	}
+	if res.status != http.StatusOK || res.body != `"done"` {
+		t.Errorf("Expected 200 with \"done\", got %d with %s", res.status, res.body)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected nil after graceful shutdown, got %v", err)
+	}
+}
+
+func TestServeAppliesServerConfig(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configured *http.Server
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = serve(ctx, listener, &Router{}, WithServerConfig(func(server *http.Server) {
+		server.ReadHeaderTimeout = time.Second
+		configured = server
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configured == nil || configured.ReadHeaderTimeout != time.Second {
+		t.Error("Expected the server to be configured")
+	}
+}
@@ -0,0 +1,69 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SSEWriter writes Server-Sent Events to a response
+type SSEWriter struct {
+	w http.ResponseWriter
+}
+
+// NewSSEWriter prepares the response for Server-Sent Events and returns a writer for sending them.
+// It returns an error without writing anything if neither the ResponseWriter nor any writer it
+// wraps supports flushing, so the handler can still respond in another way.
+func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
+	if !canFlush(w) {
+		return nil, errors.New("streaming is not supported by the ResponseWriter")
+	}
+	// Connection: keep-alive is not set as it is the default in HTTP/1.1 and invalid in HTTP/2
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disable response buffering in reverse proxies such as nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flushWriter(w)
+	return &SSEWriter{w: w}, nil
+}
+
+// Send writes an event and flushes it to the client. An empty event name sends an unnamed
+// message event. Strings and byte slices are sent as is, other data is encoded as JSON.
+func (sse *SSEWriter) Send(event string, data interface{}) error {
+	var payload string
+	switch value := data.(type) {
+	case string:
+		payload = value
+	case []byte:
+		payload = string(value)
+	default:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		payload = string(encoded)
+	}
+
+	var message strings.Builder
+	if event != "" {
+		fmt.Fprintf(&message, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(payload, "\n") {
+		fmt.Fprintf(&message, "data: %s\n", line)
+	}
+	message.WriteString("\n")
+
+	if _, err := sse.w.Write([]byte(message.String())); err != nil {
+		return err
+	}
+	sse.Flush()
+	return nil
+}
+
+// Flush sends any buffered data to the client
+func (sse *SSEWriter) Flush() {
+	flushWriter(sse.w)
+}
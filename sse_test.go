@@ -0,0 +1,70 @@
+package restapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type nonFlushingWriter struct {
+	http.ResponseWriter
+}
+
+func TestSSEWriter(t *testing.T) {
+	t.Run("Sends events until the request context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		router := &Router{}
+		router.HandleFunc("GET", "/events", func(w http.ResponseWriter, r *http.Request, routeContext *RouteContext) {
+			sse, err := NewSSEWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sse.Send("greeting", "hello\nworld")
+			sse.Send("", map[string]int{"count": 1})
+			cancel()
+			<-r.Context().Done()
+		})
+
+		req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		LoggingRouter(router, func(entry HttpLogEntry) {}).ServeHTTP(w, req)
+
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+			t.Errorf("Expected Content-Type 'text/event-stream', got '%s'", contentType)
+		}
+		if !w.Flushed {
+			t.Error("Expected the response to be flushed")
+		}
+		expected := "event: greeting\ndata: hello\ndata: world\n\ndata: {\"count\":1}\n\n"
+		if body := w.Body.String(); body != expected {
+			t.Errorf("Expected body %q, got %q", expected, body)
+		}
+	})
+
+	t.Run("Returns an error without writing when flushing is not supported", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		if _, err := NewSSEWriter(nonFlushingWriter{recorder}); err == nil {
+			t.Error("Expected error for a ResponseWriter without http.Flusher")
+		}
+		if recorder.Header().Get("Content-Type") != "" || recorder.Body.Len() != 0 {
+			t.Error("Expected nothing to be written so that the handler can respond otherwise")
+		}
+	})
+
+	t.Run("Finds the flusher behind wrappers without Flush", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		sse, err := NewSSEWriter(&hiddenRouteWriter{ResponseWriter: recorder})
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder.Flushed = false
+		sse.Send("ping", "1")
+		if !recorder.Flushed {
+			t.Error("Expected the wrapped writer to be flushed")
+		}
+		if connection := recorder.Header().Get("Connection"); connection != "" {
+			t.Errorf("Expected no Connection header, got '%s'", connection)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package restapi
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTestServer(t *testing.T) {
+	router := &Router{BasePath: "/api"}
+	router.HandleFunc("POST", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		var user testUser
+		if err := ReadJSON(r, &user); err != nil {
+			WriteError(w, NewHTTPError(http.StatusBadRequest, ""))
+			return
+		}
+		user.ID = 42
+		ctx.SetStatus(http.StatusCreated)
+		WriteJSON(w, user)
+	})
+	router.HandleFunc("DELETE", "/users/:id", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		WriteNoContent(w)
+	})
+	server := NewTestServer(router)
+	defer server.Close()
+
+	t.Run("Typed JSON request and response", func(t *testing.T) {
+		var created testUser
+		resp, err := server.DoJSON("POST", "/api/users", testUser{Name: "John Doe"}, &Response{Data: &created})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", resp.StatusCode)
+		}
+		if created != (testUser{ID: 42, Name: "John Doe"}) {
+			t.Errorf("Expected the created user, got %+v", created)
+		}
+	})
+
+	t.Run("Empty response", func(t *testing.T) {
+		var out Response
+		resp, err := server.DoJSON("DELETE", "/api/users/42", nil, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package restapi
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Warning is a non-fatal issue reported alongside a successful response, e.g. a deprecation
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type WarningMode int
+
+const (
+	// WarningsInHeaders adds warnings as Warning response headers (default)
+	WarningsInHeaders WarningMode = iota
+	// WarningsInBody adds warnings as a "warnings" array to the default JSON response template
+	WarningsInBody
+)
+
+var warningMode = WarningsInHeaders
+
+// SetWarningMode configures how warnings added with RouteContext.AddWarning are returned to the client.
+// WarningsInBody only applies to responses written with the default JSON response template.
+func SetWarningMode(mode WarningMode) {
+	warningMode = mode
+}
+
+// AddWarning attaches a warning to the response of the current request
+func (rc *RouteContext) AddWarning(code, message string) {
+	rc.warnings = append(rc.warnings, Warning{Code: code, Message: message})
+}
+
+// Warnings returns the warnings added to the current request
+func (rc *RouteContext) Warnings() []Warning {
+	return rc.warnings
+}
+
+// writeWarningHeaders adds the warnings of the route context as Warning headers
+// using the "299" miscellaneous persistent warning code
+func writeWarningHeaders(header http.Header, routeContext *RouteContext) {
+	if warningMode != WarningsInHeaders {
+		return
+	}
+	for _, warning := range routeContext.warnings {
+		header.Add("Warning", "299 - "+strconv.Quote(warning.Code+": "+warning.Message))
+	}
+}
@@ -0,0 +1,72 @@
+package restapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		ctx.AddWarning("deprecated", "use /v2/users instead")
+		ctx.AddWarning("partial", "some users could not be loaded")
+		WriteJSON(w, []string{"john"})
+	})
+
+	t.Run("Warnings are returned as headers by default", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		warnings := w.Header().Values("Warning")
+		if len(warnings) != 2 {
+			t.Fatalf("Expected 2 Warning headers, got %v", warnings)
+		}
+		if warnings[0] != `299 - "deprecated: use /v2/users instead"` {
+			t.Errorf("Unexpected Warning header '%s'", warnings[0])
+		}
+	})
+
+	t.Run("Warnings are returned in the JSON envelope in body mode", func(t *testing.T) {
+		SetWarningMode(WarningsInBody)
+		defer SetWarningMode(WarningsInHeaders)
+
+		req := httptest.NewRequest("GET", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if len(w.Header().Values("Warning")) != 0 {
+			t.Error("Expected no Warning headers in body mode")
+		}
+		var response Response
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Warnings) != 2 || response.Warnings[1] != (Warning{"partial", "some users could not be loaded"}) {
+			t.Errorf("Unexpected warnings %+v", response.Warnings)
+		}
+	})
+
+	t.Run("Responses without warnings are unchanged", func(t *testing.T) {
+		SetWarningMode(WarningsInBody)
+		defer SetWarningMode(WarningsInHeaders)
+
+		router := &Router{}
+		router.HandleFunc("GET", "/users", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+			WriteJSON(w, []string{"john"})
+		})
+		req := httptest.NewRequest("GET", "/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := response["warnings"]; ok {
+			t.Error("Expected warnings to be omitted")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package restapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// websocketGUID is the magic value from RFC 6455 used to compute Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// headerContainsToken reports whether a comma separated header contains token, ignoring case
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether the Origin header of r is missing or has the same host as the request.
+// Browsers always send Origin with WebSocket handshakes, other clients usually don't.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// UpgradeWebSocket performs the WebSocket opening handshake and hijacks the connection.
+// The caller takes over the returned connection and is responsible for reading and writing
+// frames and closing it. If the request is not a valid WebSocket upgrade request, an error
+// response is written and an error is returned.
+//
+// Browsers don't apply CORS to WebSocket handshakes, so any site could otherwise open a connection
+// with the user's cookies. checkOrigin decides whether the request is accepted, requests it rejects
+// get 403. A nil checkOrigin only accepts requests without an Origin header or whose Origin host
+// matches the Host header.
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, checkOrigin func(r *http.Request) bool) (net.Conn, *bufio.ReadWriter, error) {
+	if r.Method != "GET" {
+		http.Error(w, "WebSocket upgrade requires GET", http.StatusMethodNotAllowed)
+		return nil, nil, errors.New("websocket: upgrade requires GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Not a WebSocket upgrade request", http.StatusBadRequest)
+		return nil, nil, errors.New("websocket: missing Connection or Upgrade header")
+	}
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "WebSocket origin not allowed", http.StatusForbidden)
+		return nil, nil, errors.New("websocket: origin not allowed")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, nil, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+
+	conn, bufrw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+	if _, err := bufrw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := bufrw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, bufrw, nil
+}
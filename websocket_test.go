@@ -0,0 +1,123 @@
+package restapi
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpgradeWebSocket(t *testing.T) {
+	router := &Router{}
+	router.HandleFunc("GET", "/ws", func(w http.ResponseWriter, r *http.Request, ctx *RouteContext) {
+		conn, bufrw, err := UpgradeWebSocket(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufrw.WriteString("hello")
+		bufrw.Flush()
+	})
+	server := httptest.NewServer(LoggingRouter(router, func(entry HttpLogEntry) {}))
+	defer server.Close()
+
+	t.Run("Valid handshake switches protocols", func(t *testing.T) {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		// sample key and accept value from RFC 6455
+		conn.Write([]byte("GET /ws HTTP/1.1\r\n" +
+			"Host: " + server.Listener.Addr().String() + "\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: keep-alive, Upgrade\r\n" +
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+			"Sec-WebSocket-Version: 13\r\n\r\n"))
+
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Errorf("Expected status 101, got %d", resp.StatusCode)
+		}
+		if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("Unexpected Sec-WebSocket-Accept '%s'", accept)
+		}
+		payload := make([]byte, 5)
+		if _, err := reader.Read(payload); err != nil || string(payload) != "hello" {
+			t.Errorf("Expected to read 'hello' from the hijacked connection, got '%s' (%v)", payload, err)
+		}
+	})
+
+	t.Run("Plain request is rejected", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/ws")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Origin check", func(t *testing.T) {
+		allowPartner := func(r *http.Request) bool {
+			return r.Header.Get("Origin") == "https://partner.example.com"
+		}
+		tests := []struct {
+			name           string
+			origin         string
+			checkOrigin    func(r *http.Request) bool
+			expectedStatus int
+		}{
+			{"No Origin is allowed", "", nil, http.StatusUpgradeRequired},
+			{"Same origin is allowed", "https://api.example.com", nil, http.StatusUpgradeRequired},
+			{"Same origin ignores case", "https://API.example.com", nil, http.StatusUpgradeRequired},
+			{"Cross origin is rejected", "https://evil.example.com", nil, http.StatusForbidden},
+			{"Malformed Origin is rejected", "://", nil, http.StatusForbidden},
+			{"Custom check allows its origin", "https://partner.example.com", allowPartner, http.StatusUpgradeRequired},
+			{"Custom check replaces the default", "https://api.example.com", allowPartner, http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// version 8 makes an accepted request stop before hijacking the recorder
+				req := httptest.NewRequest("GET", "https://api.example.com/ws", nil)
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Sec-WebSocket-Version", "8")
+				if tt.origin != "" {
+					req.Header.Set("Origin", tt.origin)
+				}
+				w := httptest.NewRecorder()
+				UpgradeWebSocket(w, req, tt.checkOrigin)
+
+				if w.Code != tt.expectedStatus {
+					t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+				}
+			})
+		}
+	})
+
+	t.Run("Unsupported version asks for version 13", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "8")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUpgradeRequired {
+			t.Errorf("Expected status 426, got %d", w.Code)
+		}
+		if !strings.Contains(w.Header().Get("Sec-WebSocket-Version"), "13") {
+			t.Error("Expected Sec-WebSocket-Version 13 in the response")
+		}
+	})
+}